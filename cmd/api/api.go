@@ -3,7 +3,10 @@ package main
 import (
 	"backendwithgo/docs"
 	"backendwithgo/internal/auth"
+	"backendwithgo/internal/blob"
+	"backendwithgo/internal/events"
 	"backendwithgo/internal/mailer"
+	"backendwithgo/internal/markdown"
 	"backendwithgo/internal/store"
 	"expvar"
 	"fmt"
@@ -19,6 +22,7 @@ import (
 	"github.com/swaggo/http-swagger" // http-swagger middleware
 	"go.uber.org/zap"
 	"backendwithgo/internal/ratelimiter"
+	"backendwithgo/internal/search"
 )
 
 type application struct {
@@ -28,17 +32,105 @@ type application struct {
 	mailer mailer.Client
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+	signer        *auth.Signer
+	events        events.Broker
+	// streamsDone is closed when the server shuts down, to end the event
+	// streams that would otherwise hold Shutdown until its timeout.
+	streamsDone chan struct{}
+
+	markdown *markdown.Cache
+	blobs    blob.Store
+	search   search.Engine
+	// imageSlots holds one value per image being decoded.
+	imageSlots chan struct{}
 }
 
 type config struct {
-	addr        string
-	db          dbConfig
-	env         string
-	apiURL      string
-	mail        mailConfig
-	frontendURL string
-	auth        authConfig
-	rateLimiter ratelimiter.Config
+	addr          string
+	db            dbConfig
+	env           string
+	apiURL        string
+	externalURL   string
+	mail          mailConfig
+	frontendURL   string
+	auth          authConfig
+	rateLimiter   ratelimiter.Config
+	suggestions   suggestionsConfig
+	export        exportConfig
+	notifications notificationsConfig
+	comments      commentsConfig
+	reactions     reactionsConfig
+	posts         postsConfig
+	attachments   attachmentsConfig
+	search        searchConfig
+}
+
+type attachmentsConfig struct {
+	dir           string
+	maxPerPost    int
+	maxImageSize  int64
+	maxVideoSize  int64
+	allowVideo    bool
+	thumbnailSize int
+	// imageWorkers is how many uploaded images may be decoded at once.
+	imageWorkers int
+	// transferTimeout replaces the server read and write timeouts for
+	// uploads and downloads of attachments.
+	transferTimeout time.Duration
+	// linkExp is kept short because the signed links are not tied to a
+	// viewer: a link keeps working until it expires even if the post is
+	// made private.
+	linkExp       time.Duration
+	unusedTTL     time.Duration
+	cleanInterval time.Duration
+}
+
+type searchConfig struct {
+	// engine is mysql, the FULLTEXT index of the posts table and the
+	// default, or memory, an index held by the API and rebuilt at startup.
+	// memory only sees the writes of its own process, so each instance would
+	// rank with its own diverging copy; it is only used when singleInstance
+	// confirms that a single API instance runs.
+	engine string
+	// memoryMaxPosts bounds the memory index; above it mysql is used.
+	memoryMaxPosts int
+	singleInstance bool
+}
+
+type postsConfig struct {
+	publishInterval time.Duration
+	trashRetention  time.Duration
+	purgeInterval   time.Duration
+	renderCacheSize int
+}
+
+type reactionsConfig struct {
+	emoji []string
+}
+
+type commentsConfig struct {
+	depth           int
+	repliesPerLevel int
+	// maxDepth is the deepest a reply may be nested, kept well under the 15
+	// levels of foreign key cascades InnoDB allows.
+	maxDepth int
+}
+
+type notificationsConfig struct {
+	emailBatchWindow   time.Duration
+	unsubscribeLinkExp time.Duration
+}
+
+type exportConfig struct {
+	dir           string
+	linkExp       time.Duration
+	buildInterval time.Duration
+	cleanInterval time.Duration
+}
+
+type suggestionsConfig struct {
+	ttl             time.Duration
+	refreshInterval time.Duration
 }
 
 type authConfig struct {
@@ -83,49 +175,168 @@ func (app *application) mount() http.Handler {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
-	r.Use(middleware.Timeout(60 * time.Second))
 	if app.config.rateLimiter.Enabled {
 		r.Use(app.RateLimiterMiddleware)
 	}
 
 
 	r.Route("/v1", func(r chi.Router) {
-		r.Get("/health", app.healthCheckHandler)
-		r.With(app.BasicAuthMiddleware()).Get("/debug/vars", expvar.Handler().ServeHTTP)
-
-		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
-		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
-
-		r.Route("/posts", func(r chi.Router) {
-			r.Use(app.AuthTokenMiddleware)
-			r.Post("/", app.createPostHandler)
-			r.Route("/{postID}", func(r chi.Router) {
-				r.Use(app.postcontextMiddleware)
-				r.Get("/", app.GetPostHandler)
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
-				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
-			})
-		})
+		// SSE connections are long-lived, so the stream is kept out of the
+		// request timeout below.
+		r.With(app.AuthTokenMiddleware).Get("/stream", app.streamHandler)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(60 * time.Second))
+
+			r.Get("/health", app.healthCheckHandler)
+			r.With(app.BasicAuthMiddleware()).Get("/debug/vars", expvar.Handler().ServeHTTP)
 
-		r.Route("/users", func(r chi.Router) {
-			r.Put("/activate/{token}", app.activateUserHandler)
-			r.Route("/{userID}", func(r chi.Router) {
+			docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
+			r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
+
+			r.Route("/posts", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
-				r.Get("/", app.getUserHandler)
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Post("/", app.createPostHandler)
+				r.Post("/preview", app.previewPostHandler)
+				r.Get("/trash", app.getTrashHandler)
+				r.Post("/trash/{postID}/restore", app.restorePostHandler)
+
+				// bỏ bookmark không cần xem được bài: bài đã vào thùng rác hay
+				// không còn hiển thị vẫn phải bỏ được
+				r.With(app.postcontextMiddleware).Put("/{postID}/bookmark", app.saveBookmarkHandler)
+				r.Delete("/{postID}/bookmark", app.deleteBookmarkHandler)
+
+				r.Route("/{postID}", func(r chi.Router) {
+					r.Use(app.postcontextMiddleware)
+					r.Get("/", app.GetPostHandler)
+					r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
+					r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+
+					r.Route("/reactions", func(r chi.Router) {
+						r.Get("/", app.getPostReactorsHandler)
+						r.Post("/", app.togglePostReactionHandler)
+					})
+
+					r.Post("/publish", app.publishPostHandler)
+
+					r.Route("/revisions", func(r chi.Router) {
+						r.Get("/", app.getPostRevisionsHandler)
+						r.Get("/diff", app.diffPostRevisionsHandler)
+						r.Post("/{version}/restore", app.checkPostOwnership("moderator", app.restorePostRevisionHandler))
+					})
+
+					r.Post("/repost", app.repostHandler)
+					r.Delete("/repost", app.undoRepostHandler)
+
+					r.Route("/comments", func(r chi.Router) {
+						r.Get("/", app.getCommentsHandler)
+						r.Post("/", app.createCommentHandler)
+						r.Route("/{commentID}", func(r chi.Router) {
+							r.Use(app.commentContextMiddleware)
+							r.Patch("/", app.updateCommentHandler)
+							r.Delete("/", app.deleteCommentHandler)
+							r.Get("/replies", app.getCommentRepliesHandler)
+							r.Post("/reactions", app.toggleCommentReactionHandler)
+						})
+					})
+				})
 			})
 
-			r.Group(func(r chi.Router) {
+			r.With(app.AuthTokenMiddleware).Get("/search", app.searchHandler)
+
+			r.Route("/tags", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
-				r.Get("/feed", app.getUserFeedHandler)
+				r.Get("/trending", app.getTrendingTagsHandler)
+				r.Get("/autocomplete", app.autocompleteTagsHandler)
+				r.Route("/{tag}", func(r chi.Router) {
+					r.Get("/posts", app.getTagPostsHandler)
+					r.Put("/follow", app.followTagHandler)
+					r.Put("/unfollow", app.unfollowTagHandler)
+				})
+			})
+
+			r.Route("/users", func(r chi.Router) {
+				r.Put("/activate/{token}", app.activateUserHandler)
+				r.Route("/{userID}", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Get("/", app.getUserHandler)
+					r.Put("/follow", app.followUserHandler)
+					r.Put("/unfollow", app.unfollowUserHandler)
+					r.Put("/block", app.blockUserHandler)
+					r.Put("/unblock", app.unblockUserHandler)
+				})
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Get("/feed", app.getUserFeedHandler)
+					r.Get("/search", app.searchUsersHandler)
+
+					r.Route("/me", func(r chi.Router) {
+						r.Put("/privacy", app.updatePrivacyHandler)
+
+						r.Post("/export", app.createDataExportHandler)
+						r.Get("/mentions", app.getMentionsHandler)
+						r.Get("/drafts", app.getDraftsHandler)
+						r.Get("/tags", app.getFollowedTagsHandler)
+
+						r.Route("/suggestions", func(r chi.Router) {
+							r.Get("/", app.getSuggestionsHandler)
+							r.Delete("/{userID}", app.dismissSuggestionHandler)
+						})
+
+						r.Route("/bookmarks", func(r chi.Router) {
+							r.Get("/", app.getBookmarksHandler)
+							r.Get("/collections", app.getBookmarkCollectionsHandler)
+							r.Post("/collections", app.createBookmarkCollectionHandler)
+							r.Delete("/collections/{collectionID}", app.deleteBookmarkCollectionHandler)
+						})
+
+						r.Route("/mutes", func(r chi.Router) {
+							r.Get("/", app.getMutesHandler)
+							r.Post("/", app.createMuteHandler)
+							r.Delete("/{muteID}", app.deleteMuteHandler)
+						})
+
+						r.Route("/follow-requests", func(r chi.Router) {
+							r.Get("/", app.getFollowRequestsHandler)
+							r.Put("/{userID}/approve", app.approveFollowRequestHandler)
+							r.Put("/{userID}/reject", app.rejectFollowRequestHandler)
+						})
+					})
+				})
 			})
-		})
 
-		// Public routes
-		r.Route("/authentication", func(r chi.Router) {
-			r.Post("/user", app.registerUserHandler)
-			r.Post("/token", app.createTokenHandler)
+			r.Route("/notifications", func(r chi.Router) {
+				r.Get("/unsubscribe", app.confirmUnsubscribeNotificationEmailsHandler)
+				r.Post("/unsubscribe", app.unsubscribeNotificationEmailsHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Get("/", app.getNotificationsHandler)
+					r.Get("/preferences", app.getNotificationPreferencesHandler)
+					r.Put("/preferences", app.updateNotificationPreferencesHandler)
+					r.Put("/read-all", app.markAllNotificationsReadHandler)
+					r.Put("/{notificationID}/read", app.markNotificationReadHandler)
+				})
+			})
+
+			// Public routes
+			r.Get("/exports/{exportID}/download", app.downloadDataExportHandler)
+
+			r.Route("/attachments", func(r chi.Router) {
+				r.Get("/{attachmentID}/{variant}", app.getAttachmentFileHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Post("/", app.uploadAttachmentHandler)
+					r.Patch("/{attachmentID}", app.updateAttachmentHandler)
+				})
+			})
+
+			r.Route("/authentication", func(r chi.Router) {
+				r.Post("/user", app.registerUserHandler)
+				r.Post("/token", app.createTokenHandler)
+			})
 		})
 	})
 
@@ -145,7 +356,14 @@ func (app *application) run(mux http.Handler) error {
 		ReadTimeout:  time.Second * 10,
 		IdleTimeout:  time.Minute,
 	}
+	srv.RegisterOnShutdown(func() {
+		close(app.streamsDone)
+	})
+
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	app.startBackgroundJobs(jobsCtx)
 
 	// Graceful Shutdown
 	shutdown := make(chan error)
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"backendwithgo/internal/blob"
+	"backendwithgo/internal/media"
+	"backendwithgo/internal/store"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+const maxAltTextLength = 1000
+
+type UpdateAttachmentPayload struct {
+	AltText string `json:"alt_text" validate:"max=1000"`
+}
+
+// UploadAttachment godoc
+//
+//	@Summary		Uploads an attachment
+//	@Description	Uploads an image (JPEG, PNG or GIF) or a short video (MP4 or WebM) to attach to a post. Reference the returned ID in attachment_ids when creating the post; uploads left unused are deleted after a day.
+//	@Tags			attachments
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file		formData	file	true	"Image or video"
+//	@Param			alt_text	formData	string	false	"Description of the media for screen readers"
+//	@Success		201			{object}	store.Attachment
+//	@Failure		400			{object}	error
+//	@Failure		413			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/attachments [post]
+func (app *application) uploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := app.config.attachments
+
+	// ReadTimeout và WriteTimeout của server quá ngắn cho file lớn qua mạng chậm
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(cfg.transferTimeout)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, max(cfg.maxImageSize, cfg.maxVideoSize)+1<<20)
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			app.payloadTooLargeResponse(w, r, errors.New("file is too large"))
+			return
+		}
+		app.badrequestresponse(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	altText := r.FormValue("alt_text")
+	if utf8.RuneCountInString(altText) > maxAltTextLength {
+		app.badrequestresponse(w, r, fmt.Errorf("alt_text must be at most %d characters", maxAltTextLength))
+		return
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	contentType, kind, err := media.Detect(head[:n])
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	limit := cfg.maxImageSize
+	if kind == media.KindVideo {
+		if !cfg.allowVideo {
+			app.badrequestresponse(w, r, errors.New("video attachments are not allowed"))
+			return
+		}
+		limit = cfg.maxVideoSize
+	}
+	if header.Size > limit {
+		app.payloadTooLargeResponse(w, r, fmt.Errorf("%s attachments must be at most %d bytes", kind, limit))
+		return
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	attachment := &store.Attachment{
+		UserID:      user.ID,
+		Kind:        kind,
+		ContentType: contentType,
+		Size:        header.Size,
+		AltText:     altText,
+		StorageKey:  fmt.Sprintf("attachments/%d/%s", user.ID, uuid.NewString()),
+	}
+
+	var content io.Reader = file
+	if kind == media.KindImage {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		// giải mã ảnh tốn nhiều bộ nhớ: giới hạn số ảnh xử lý cùng lúc
+		select {
+		case app.imageSlots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		thumb, width, height, err := media.Thumbnail(data, cfg.thumbnailSize)
+		<-app.imageSlots
+		if err != nil {
+			app.badrequestresponse(w, r, fmt.Errorf("invalid image: %w", err))
+			return
+		}
+
+		thumbnailKey := attachment.StorageKey + "-thumbnail"
+		if err := app.blobs.Put(ctx, thumbnailKey, bytes.NewReader(thumb)); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		attachment.Width = width
+		attachment.Height = height
+		attachment.ThumbnailKey = &thumbnailKey
+		content = bytes.NewReader(data)
+	}
+
+	if err := app.blobs.Put(ctx, attachment.StorageKey, content); err != nil {
+		app.deleteAttachmentFiles(ctx, attachment)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Attachments.Create(ctx, attachment); err != nil {
+		app.deleteAttachmentFiles(ctx, attachment)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.signAttachment(attachment)
+	if err := app.jsonResponse(w, http.StatusCreated, attachment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateAttachment godoc
+//
+//	@Summary		Updates an attachment
+//	@Description	Changes the alt text of an attachment of the authenticated user
+//	@Tags			attachments
+//	@Accept			json
+//	@Produce		json
+//	@Param			attachmentID	path		int						true	"Attachment ID"
+//	@Param			payload			body		UpdateAttachmentPayload	true	"Alt text"
+//	@Success		200				{object}	store.Attachment
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/attachments/{attachmentID} [patch]
+func (app *application) updateAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "attachmentID"), 10, 64)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	var payload UpdateAttachmentPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	var Validate = validator.New()
+	if err := Validate.Struct(payload); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	if err := app.store.Attachments.UpdateAltText(ctx, user.ID, attachmentID, payload.AltText); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	attachment, err := app.store.Attachments.GetByID(ctx, attachmentID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.signAttachment(attachment)
+	if err := app.jsonResponse(w, http.StatusOK, attachment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetAttachmentFile godoc
+//
+//	@Summary		Downloads an attachment
+//	@Description	Serves an attachment (variant file) or its thumbnail (variant thumbnail) through the signed link returned with it. Attachments of posts in the trash are not served.
+//	@Tags			attachments
+//	@Produce		octet-stream
+//	@Param			attachmentID	path		int		true	"Attachment ID"
+//	@Param			variant			path		string	true	"file or thumbnail"
+//	@Param			expires			query		int		true	"Link expiry (unix time)"
+//	@Param			signature		query		string	true	"Link signature"
+//	@Success		200				{file}		file
+//	@Failure		403				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Router			/attachments/{attachmentID}/{variant} [get]
+func (app *application) getAttachmentFileHandler(w http.ResponseWriter, r *http.Request) {
+	attachmentIDParam := chi.URLParam(r, "attachmentID")
+	attachmentID, err := strconv.ParseInt(attachmentIDParam, 10, 64)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	variant := chi.URLParam(r, "variant")
+	if variant != "file" && variant != "thumbnail" {
+		app.notfoundresponse(w, r, fmt.Errorf("unknown attachment variant %q", variant))
+		return
+	}
+
+	expiresParam := r.URL.Query().Get("expires")
+	expires, err := strconv.ParseInt(expiresParam, 10, 64)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	signature := r.URL.Query().Get("signature")
+	if !app.signer.Verify(signature, "attachment", attachmentIDParam, variant, expiresParam) || time.Now().Unix() > expires {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	ctx := r.Context()
+
+	attachment, err := app.store.Attachments.GetByID(ctx, attachmentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// link vẫn còn hạn nhưng bài đã vào thùng rác thì không phục vụ nữa
+	if attachment.PostID != nil {
+		if _, err := app.store.Posts.GetByID(ctx, *attachment.PostID); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				app.notfoundresponse(w, r, fmt.Errorf("post of attachment %d not found", attachment.ID))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	key, contentType := attachment.StorageKey, attachment.ContentType
+	if variant == "thumbnail" {
+		if attachment.ThumbnailKey == nil {
+			app.notfoundresponse(w, r, fmt.Errorf("attachment %d has no thumbnail", attachment.ID))
+			return
+		}
+		key, contentType = *attachment.ThumbnailKey, "image/jpeg"
+	}
+
+	content, err := app.blobs.Open(ctx, key)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	defer content.Close()
+
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(app.config.attachments.transferTimeout)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(attachmentLinkStep.Seconds())))
+
+	if rs, ok := content.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", attachment.CreatedAt, rs)
+		return
+	}
+	if _, err := io.Copy(w, content); err != nil {
+		app.logger.Errorw("error serving attachment", "attachment", attachment.ID, "error", err.Error())
+	}
+}
+
+// attachmentLinkStep is what attachment link expiries are rounded down to.
+const attachmentLinkStep = 5 * time.Minute
+
+// signAttachment sets the signed links of an attachment. Links expire on a
+// multiple of attachmentLinkStep so that they stay the same, and cacheable,
+// for that long.
+func (app *application) signAttachment(a *store.Attachment) {
+	id := strconv.FormatInt(a.ID, 10)
+	expires := strconv.FormatInt(time.Now().Add(app.config.attachments.linkExp).Truncate(attachmentLinkStep).Unix(), 10)
+
+	link := func(variant string) string {
+		return fmt.Sprintf("%s/v1/attachments/%s/%s?expires=%s&signature=%s",
+			app.config.externalURL, id, variant, expires, app.signer.Sign("attachment", id, variant, expires))
+	}
+
+	a.URL = link("file")
+	if a.ThumbnailKey != nil {
+		a.ThumbnailURL = link("thumbnail")
+	}
+}
+
+// loadAttachments loads the attachments of posts, with signed links.
+func (app *application) loadAttachments(ctx context.Context, posts ...*store.Post) error {
+	ids := make([]int64, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+
+	attachments, err := app.store.Attachments.GetForPosts(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		p.Attachments = attachments[p.ID]
+		if p.Attachments == nil {
+			p.Attachments = []store.Attachment{}
+		}
+		for i := range p.Attachments {
+			app.signAttachment(&p.Attachments[i])
+		}
+	}
+
+	return nil
+}
+
+// attachPostAttachments loads the attachments of a page of posts.
+func (app *application) attachPostAttachments(ctx context.Context, posts []store.PostWithData) error {
+	ptrs := make([]*store.Post, 0, len(posts))
+	for i := range posts {
+		ptrs = append(ptrs, &posts[i].Post)
+	}
+	return app.loadAttachments(ctx, ptrs...)
+}
+
+// deleteUnusedAttachments deletes the uploads that were never attached to a
+// post and the attachments of purged posts, with their files.
+func (app *application) deleteUnusedAttachments(ctx context.Context) error {
+	for {
+		attachments, err := app.store.Attachments.DeleteUnattached(ctx, app.config.attachments.unusedTTL, 100)
+		if err != nil {
+			return err
+		}
+
+		for i := range attachments {
+			app.deleteAttachmentFiles(ctx, &attachments[i])
+		}
+
+		if len(attachments) < 100 {
+			return nil
+		}
+	}
+}
+
+func (app *application) deleteAttachmentFiles(ctx context.Context, a *store.Attachment) {
+	keys := []string{a.StorageKey}
+	if a.ThumbnailKey != nil {
+		keys = append(keys, *a.ThumbnailKey)
+	}
+
+	for _, key := range keys {
+		if err := app.blobs.Delete(ctx, key); err != nil {
+			app.logger.Errorw("error deleting attachment file", "key", key, "error", err.Error())
+		}
+	}
+}
@@ -14,9 +14,10 @@ import (
 )
 
 type RegisterUserPayload struct {
-	Username string `json:"username" validate:"required,max=100"`
-	Email    string `json:"email" validate:"required,email,max=255"`
-	Password string `json:"password" validate:"required,min=3,max=72"`
+	Username    string `json:"username" validate:"required,max=100"`
+	DisplayName string `json:"display_name" validate:"max=255"`
+	Email       string `json:"email" validate:"required,email,max=255"`
+	Password    string `json:"password" validate:"required,min=3,max=72"`
 }
 
 type UserWithToken struct {
@@ -49,8 +50,9 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	user := &store.User{
-		Username: payload.Username,
-		Email:    payload.Email,
+		Username:    payload.Username,
+		DisplayName: payload.DisplayName,
+		Email:       payload.Email,
 		Role: store.Role{
 			Name: "user",
 		},
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"backendwithgo/internal/store"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type SaveBookmarkPayload struct {
+	CollectionID *int64 `json:"collection_id"`
+}
+
+type CreateBookmarkCollectionPayload struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// SaveBookmark godoc
+//
+//	@Summary		Bookmarks a post
+//	@Description	Saves a post for later, optionally in one of the user's collections. Saving an already bookmarked post moves it to the given collection.
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int					true	"Post ID"
+//	@Param			payload	body		SaveBookmarkPayload	false	"Bookmark payload"
+//	@Success		200		{object}	store.Bookmark
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [put]
+func (app *application) saveBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	var payload SaveBookmarkPayload
+	if r.ContentLength != 0 {
+		if err := ReadJSON(w, r, &payload); err != nil {
+			app.badrequestresponse(w, r, err)
+			return
+		}
+	}
+
+	post := getpostCtx(r)
+	user := app.getUserfromContext(r)
+
+	bookmark := &store.Bookmark{
+		UserID:       user.ID,
+		PostID:       post.ID,
+		CollectionID: payload.CollectionID,
+	}
+
+	if err := app.store.Bookmarks.Save(r.Context(), bookmark); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.badrequestresponse(w, r, errors.New("collection not found"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, bookmark); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteBookmark godoc
+//
+//	@Summary		Removes a bookmark
+//	@Description	Removes a post from the bookmarks of the authenticated user, even if the post was trashed or is no longer visible
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [delete]
+func (app *application) deleteBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+
+	if err := app.store.Bookmarks.Delete(r.Context(), user.ID, postID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetBookmarks godoc
+//
+//	@Summary		Lists the bookmarked posts
+//	@Description	Lists the posts bookmarked by the authenticated user, most recently saved first. Posts that were deleted or are no longer visible are left out.
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			collection_id	query		int		false	"Only bookmarks of this collection"
+//	@Param			limit			query		int		false	"Page size"
+//	@Param			cursor			query		string	false	"next_cursor of the previous page"
+//	@Success		200				{object}	store.BookmarkPage
+//	@Failure		400				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks [get]
+func (app *application) getBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	cq := store.CursorQuery{Limit: 20}
+	cq, err := cq.Parse(r)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	var Validate = validator.New()
+	if err := Validate.Struct(cq); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	var collectionID *int64
+	if param := r.URL.Query().Get("collection_id"); param != "" {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			app.badrequestresponse(w, r, err)
+			return
+		}
+		collectionID = &id
+	}
+
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	page, err := app.store.Bookmarks.GetPosts(ctx, user.ID, collectionID, cq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badrequestresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.enrichPosts(ctx, user.ID, page.Posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetBookmarkCollections godoc
+//
+//	@Summary		Lists the bookmark collections
+//	@Description	Lists the bookmark collections of the authenticated user
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Success		200	{object}	[]store.BookmarkCollection
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks/collections [get]
+func (app *application) getBookmarkCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserfromContext(r)
+
+	collections, err := app.store.Bookmarks.GetCollections(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, collections); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CreateBookmarkCollection godoc
+//
+//	@Summary		Creates a bookmark collection
+//	@Description	Creates a named, private bookmark collection
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateBookmarkCollectionPayload	true	"Collection payload"
+//	@Success		201		{object}	store.BookmarkCollection
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks/collections [post]
+func (app *application) createBookmarkCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateBookmarkCollectionPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+
+	var Validate = validator.New()
+	if err := Validate.Struct(payload); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+	collection := &store.BookmarkCollection{
+		UserID: user.ID,
+		Name:   payload.Name,
+	}
+
+	if err := app.store.Bookmarks.CreateCollection(r.Context(), collection); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, errors.New("a collection with that name already exists"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, collection); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteBookmarkCollection godoc
+//
+//	@Summary		Deletes a bookmark collection
+//	@Description	Deletes a bookmark collection. Its bookmarks are kept outside of any collection.
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			collectionID	path		int	true	"Collection ID"
+//	@Success		204				{string}	string
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks/collections/{collectionID} [delete]
+func (app *application) deleteBookmarkCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(chi.URLParam(r, "collectionID"), 10, 64)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+
+	if err := app.store.Bookmarks.DeleteCollection(r.Context(), user.ID, collectionID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"backendwithgo/internal/events"
+	"backendwithgo/internal/store"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type commentKey string
+
+const commentCtxKey commentKey = "comment"
+
+type CreateCommentPayload struct {
+	Content  string `json:"content" validate:"required,max=1000"`
+	ParentID *int64 `json:"parent_id"`
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+// GetComments godoc
+//
+//	@Summary		Lists the comments of a post
+//	@Description	Lists a page of the top-level comments of a post with their replies as a tree
+//	@Tags			comments
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			sort	query		string	false	"newest (default), oldest or top"
+//	@Param			limit	query		int		false	"Page size"
+//	@Param			cursor	query		string	false	"next_cursor of the previous page"
+//	@Param			depth	query		int		false	"Levels of replies to load"
+//	@Param			replies	query		int		false	"Replies to load per comment and level"
+//	@Success		200		{object}	store.CommentPage
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [get]
+func (app *application) getCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getpostCtx(r)
+
+	page, err := app.getCommentPage(w, r, post.ID)
+	if err != nil {
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CreateComment godoc
+//
+//	@Summary		Comments on a post
+//	@Description	Creates a comment on a post, or a reply to one of its comments when parent_id is set
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int						true	"Post ID"
+//	@Param			payload	body		CreateCommentPayload	true	"Comment payload"
+//	@Success		201		{object}	store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [post]
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateCommentPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	if err := validateCommentContent(&payload.Content); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	var Validate = validator.New()
+	if err := Validate.Struct(payload); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	post := getpostCtx(r)
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	comment := &store.Comment{
+		PostID:  post.ID,
+		UserID:  user.ID,
+		Content: payload.Content,
+		User: store.User{
+			ID:       user.ID,
+			Username: user.Username,
+		},
+	}
+
+	if payload.ParentID != nil {
+		parent, err := app.store.Comments.GetByID(ctx, *payload.ParentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				app.badrequestresponse(w, r, errors.New("parent comment not found"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if parent.PostID != post.ID || parent.IsDeleted {
+			app.badrequestresponse(w, r, errors.New("cannot reply to this comment"))
+			return
+		}
+		if parent.Depth >= app.config.comments.maxDepth {
+			app.badrequestresponse(w, r, fmt.Errorf("replies can be nested at most %d levels deep", app.config.comments.maxDepth))
+			return
+		}
+
+		comment.ParentID = &parent.ID
+		comment.Depth = parent.Depth + 1
+	}
+
+	if err := app.store.Comments.Create(ctx, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	mentions, mentioned, err := app.store.Mentions.Sync(ctx, post.ID, &comment.ID, comment.Content)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	comment.Mentions = mentions
+
+	app.notifyComment(ctx, post, comment)
+	app.notifyMentions(ctx, user.ID, post, mentioned)
+	app.publishCommentEvent(ctx, post, events.CommentCreated, comment)
+
+	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateComment godoc
+//
+//	@Summary		Edits a comment
+//	@Description	Edits a comment. Only the author can edit a comment.
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int						true	"Post ID"
+//	@Param			commentID	path		int						true	"Comment ID"
+//	@Param			payload		body		UpdateCommentPayload	true	"Comment payload"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentCtx(r)
+	user := app.getUserfromContext(r)
+
+	if comment.IsDeleted {
+		app.notfoundresponse(w, r, errors.New("comment was deleted"))
+		return
+	}
+
+	if comment.UserID != user.ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	var payload UpdateCommentPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	if err := validateCommentContent(&payload.Content); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	var Validate = validator.New()
+	if err := Validate.Struct(payload); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	post := getpostCtx(r)
+	ctx := r.Context()
+
+	comment.Content = payload.Content
+	if err := app.store.Comments.Update(ctx, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	mentions, mentioned, err := app.store.Mentions.Sync(ctx, post.ID, &comment.ID, comment.Content)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	comment.Mentions = mentions
+
+	app.notifyMentions(ctx, user.ID, post, mentioned)
+	app.publishCommentEvent(ctx, post, events.CommentUpdated, comment)
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteComment godoc
+//
+//	@Summary		Deletes a comment
+//	@Description	Deletes a comment. The author, the owner of the post and moderators can delete a comment. A comment with replies is kept as a "[deleted]" placeholder.
+//	@Tags			comments
+//	@Produce		json
+//	@Param			postID		path		int	true	"Post ID"
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Success		204			{string}	string
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentCtx(r)
+	post := getpostCtx(r)
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	if comment.IsDeleted {
+		app.notfoundresponse(w, r, errors.New("comment was deleted"))
+		return
+	}
+
+	if comment.UserID != user.ID && post.UserID != user.ID {
+		allowed, err := app.checkRolePrecedence(ctx, user, "moderator")
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	if err := app.store.Comments.Delete(ctx, comment.ID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.publishCommentEvent(ctx, post, events.CommentDeleted, comment)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCommentReplies godoc
+//
+//	@Summary		Loads more replies of a comment
+//	@Description	Lists the replies of a comment after the cursor returned as replies_cursor, oldest first, with their own replies as a tree
+//	@Tags			comments
+//	@Produce		json
+//	@Param			postID		path		int	true	"Post ID"
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Param			after		query		int	false	"Replies cursor"
+//	@Param			depth		query		int	false	"Levels of replies to load"
+//	@Param			replies		query		int	false	"Replies to load per comment and level"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID}/replies [get]
+func (app *application) getCommentRepliesHandler(w http.ResponseWriter, r *http.Request) {
+	tq, err := app.parseCommentTreeQuery(r)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	comment := getCommentCtx(r)
+	ctx := r.Context()
+
+	if err := app.store.Comments.GetReplies(ctx, comment, tq); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	replies := []store.Comment{*comment}
+	if err := app.attachCommentMentions(ctx, replies); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.attachCommentReactions(ctx, app.getUserfromContext(r).ID, replies); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, replies[0]); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getCommentPage loads the page of comments asked for by the query string,
+// writing the error response itself when it fails.
+func (app *application) getCommentPage(w http.ResponseWriter, r *http.Request, postID int64) (*store.CommentPage, error) {
+	pq := store.CommentPageQuery{
+		Sort:  store.CommentSortNewest,
+		Limit: 20,
+	}
+
+	pq, err := pq.Parse(r)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return nil, err
+	}
+
+	var Validate = validator.New()
+	if err := Validate.Struct(pq); err != nil {
+		app.badrequestresponse(w, r, err)
+		return nil, err
+	}
+
+	tq, err := app.parseCommentTreeQuery(r)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return nil, err
+	}
+
+	ctx := r.Context()
+	page, err := app.store.Comments.GetByPostID(ctx, postID, pq, tq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badrequestresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, err
+	}
+
+	if err := app.attachCommentMentions(ctx, page.Comments); err != nil {
+		app.internalServerError(w, r, err)
+		return nil, err
+	}
+
+	if err := app.attachCommentReactions(ctx, app.getUserfromContext(r).ID, page.Comments); err != nil {
+		app.internalServerError(w, r, err)
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// parseCommentTreeQuery reads the depth and replies-per-level of a comment
+// tree request, defaulting to the configured values.
+func (app *application) parseCommentTreeQuery(r *http.Request) (store.CommentTreeQuery, error) {
+	tq := store.CommentTreeQuery{
+		Depth:   app.config.comments.depth,
+		Replies: app.config.comments.repliesPerLevel,
+	}
+
+	tq, err := tq.Parse(r)
+	if err != nil {
+		return tq, err
+	}
+
+	var Validate = validator.New()
+	if err := Validate.Struct(tq); err != nil {
+		return tq, err
+	}
+
+	return tq, nil
+}
+
+// validateCommentContent trims the content so that whitespace-only comments
+// are rejected as empty.
+func validateCommentContent(content *string) error {
+	*content = strings.TrimSpace(*content)
+	if *content == "" {
+		return errors.New("comment content must not be empty")
+	}
+	return nil
+}
+
+func (app *application) commentContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+		if err != nil {
+			app.badrequestresponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		comment, err := app.store.Comments.GetByID(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				app.notfoundresponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		// comment phải thuộc về post trên URL
+		if comment.PostID != getpostCtx(r).ID {
+			app.notfoundresponse(w, r, errors.New("comment does not belong to post"))
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentCtxKey, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentCtx(r *http.Request) *store.Comment {
+	comment, _ := r.Context().Value(commentCtxKey).(*store.Comment)
+	return comment
+}
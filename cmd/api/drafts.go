@@ -0,0 +1,150 @@
+package main
+
+import (
+	"backendwithgo/internal/store"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// GetDrafts godoc
+//
+//	@Summary		Lists the unpublished posts
+//	@Description	Lists the draft and scheduled posts of the authenticated user, most recently edited first
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.Post
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/drafts [get]
+func (app *application) getDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	var validate = validator.New()
+	fq := store.PaginationQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(fq); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+
+	drafts, err := app.store.Posts.GetDrafts(r.Context(), user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	posts := make([]*store.Post, 0, len(drafts))
+	for i := range drafts {
+		posts = append(posts, &drafts[i])
+	}
+	if err := app.loadAttachments(r.Context(), posts...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range drafts {
+		app.renderPost(&drafts[i])
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, drafts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// PublishPost godoc
+//
+//	@Summary		Publishes a post now
+//	@Description	Publishes a draft or scheduled post of the authenticated user immediately
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	store.Post
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/publish [post]
+func (app *application) publishPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getpostCtx(r)
+	user := app.getUserfromContext(r)
+
+	if post.UserID != user.ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	ctx := r.Context()
+	if err := app.store.Posts.Publish(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.conflictResponse(w, r, errors.New("post is already published"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.announcePost(ctx, post)
+
+	app.renderPost(post)
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// publishScheduledPosts publishes the scheduled posts that are due.
+func (app *application) publishScheduledPosts(ctx context.Context) error {
+	for {
+		posts, err := app.store.Posts.PublishDue(ctx, 100)
+		if err != nil {
+			return err
+		}
+
+		for i := range posts {
+			app.announcePost(ctx, &posts[i])
+		}
+
+		if len(posts) < 100 {
+			return nil
+		}
+	}
+}
+
+// announcePost does what publishing a post triggers, for posts published
+// after they were written: indexing for search, mention notifications and
+// the feed event.
+func (app *application) announcePost(ctx context.Context, post *store.Post) {
+	app.indexPost(ctx, post)
+
+	mentions, err := app.store.Mentions.GetForPosts(ctx, []int64{post.ID})
+	if err != nil {
+		app.logger.Errorw("error loading mentions", "post", post.ID, "error", err.Error())
+	}
+
+	userIDs := make([]int64, 0, len(mentions[post.ID]))
+	for _, m := range mentions[post.ID] {
+		userIDs = append(userIDs, m.UserID)
+	}
+
+	if notifiesMentions(post) {
+		app.notifyMentions(ctx, post.UserID, post, userIDs)
+	}
+	app.publishNewPost(ctx, post)
+}
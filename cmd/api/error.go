@@ -46,6 +46,38 @@ func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request
 	writeJSONError(w, http.StatusForbidden, "forbidden")
 }
 
+func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("conflict", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJSONError(w, http.StatusConflict, err.Error())
+}
+
+func (app *application) payloadTooLargeResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("payload too large", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJSONError(w, http.StatusRequestEntityTooLarge, err.Error())
+}
+
+func (app *application) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("precondition required", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJSONError(w, http.StatusPreconditionRequired, err.Error())
+}
+
+// editConflictResponse reports a write based on a stale version along with
+// the current representation, so the client can merge and retry.
+func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request, status int, etag string, current any) {
+	app.logger.Warnw("edit conflict", "method", r.Method, "path", r.URL.Path, "status", status)
+
+	type envelope struct {
+		Error string `json:"error"`
+		Data  any    `json:"data"`
+	}
+
+	w.Header().Set("ETag", etag)
+	WriteJSON(w, status, &envelope{Error: "the resource was modified, retry with the current version", Data: current})
+}
+
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter string) {
 	app.logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
 
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"archive/zip"
+	"backendwithgo/internal/mailer"
+	"backendwithgo/internal/store"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateDataExport godoc
+//
+//	@Summary		Exports the user data
+//	@Description	Queues a ZIP archive with everything stored about the authenticated user. A signed download link is emailed when it is ready; the archive is deleted when the link expires.
+//	@Tags			users
+//	@Produce		json
+//	@Success		202	{object}	store.DataExport
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/export [post]
+func (app *application) createDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserfromContext(r)
+
+	export := &store.DataExport{UserID: user.ID}
+	if err := app.store.Exports.Create(r.Context(), export); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, errors.New("an export is already being prepared"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, export); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DownloadDataExport godoc
+//
+//	@Summary		Downloads a data export
+//	@Description	Downloads a data export archive through the signed link sent by email
+//	@Tags			users
+//	@Produce		application/zip
+//	@Param			exportID	path		int		true	"Export ID"
+//	@Param			expires		query		int		true	"Link expiry (unix time)"
+//	@Param			signature	query		string	true	"Link signature"
+//	@Success		200			{file}		file
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/exports/{exportID}/download [get]
+func (app *application) downloadDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	exportIDParam := chi.URLParam(r, "exportID")
+	exportID, err := strconv.ParseInt(exportIDParam, 10, 64)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	expiresParam := r.URL.Query().Get("expires")
+	if _, err := strconv.ParseInt(expiresParam, 10, 64); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	signature := r.URL.Query().Get("signature")
+	if !app.signer.Verify(signature, "export", exportIDParam, expiresParam) {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	// hạn của link được kiểm tra theo đồng hồ của database, cùng với job xoá file
+	export, err := app.store.Exports.GetReady(r.Context(), exportID, app.config.export.linkExp)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, fmt.Errorf("export %d is not available", exportID))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%d.zip"`, export.ID))
+	http.ServeFile(w, r, export.FilePath)
+}
+
+// exportBuildTimeout bounds the build of one export. Exports started more
+// than twice as long ago and still pending were interrupted by a restart.
+const exportBuildTimeout = 5 * time.Minute
+
+// buildDataExports builds the pending exports one at a time, claiming each in
+// the database so that several API instances never build the same one.
+func (app *application) buildDataExports(ctx context.Context) error {
+	failed, err := app.store.Exports.FailStale(ctx, 2*exportBuildTimeout)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		app.logger.Warnw("interrupted data exports marked failed", "count", failed)
+	}
+
+	for {
+		export, err := app.store.Exports.ClaimPending(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		app.buildDataExport(ctx, export)
+	}
+}
+
+// buildDataExport writes the archive, marks the export ready and emails the
+// download link to the user.
+func (app *application) buildDataExport(ctx context.Context, export *store.DataExport) {
+	ctx, cancel := context.WithTimeout(ctx, exportBuildTimeout)
+	defer cancel()
+
+	exportID := export.ID
+	user, err := app.store.Users.GetByID(ctx, export.UserID)
+	if err != nil {
+		app.failDataExport(ctx, exportID, err)
+		return
+	}
+
+	filePath, err := app.writeDataExport(ctx, exportID, user.ID)
+	if err != nil {
+		app.failDataExport(ctx, exportID, err)
+		return
+	}
+
+	completedAt, err := app.store.Exports.MarkReady(ctx, exportID, filePath)
+	if err != nil {
+		app.logger.Errorw("error marking data export ready", "export", exportID, "error", err.Error())
+		return
+	}
+
+	expiresAt := completedAt.Add(app.config.export.linkExp)
+	id := strconv.FormatInt(exportID, 10)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	downloadURL := fmt.Sprintf("%s/v1/exports/%s/download?expires=%s&signature=%s",
+		app.config.externalURL, id, expires, app.signer.Sign("export", id, expires))
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username    string
+		DownloadURL string
+		ExpiresAt   string
+	}{
+		Username:    user.Username,
+		DownloadURL: downloadURL,
+		ExpiresAt:   expiresAt.Format(time.RFC1123),
+	}
+
+	status, err := app.mailer.Send(mailer.DataExportTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		app.logger.Errorw("error sending data export email", "export", exportID, "error", err.Error())
+		return
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+}
+
+func (app *application) failDataExport(ctx context.Context, exportID int64, err error) {
+	app.logger.Errorw("error building data export", "export", exportID, "error", err.Error())
+	if err := app.store.Exports.MarkFailed(ctx, exportID, err.Error()); err != nil {
+		app.logger.Errorw("error marking data export failed", "export", exportID, "error", err.Error())
+	}
+}
+
+// deleteExpiredDataExports deletes the archives whose download link expired.
+func (app *application) deleteExpiredDataExports(ctx context.Context) error {
+	for {
+		exports, err := app.store.Exports.ExpireReady(ctx, app.config.export.linkExp, 100)
+		if err != nil {
+			return err
+		}
+
+		for _, export := range exports {
+			if err := os.Remove(export.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				app.logger.Errorw("error deleting data export file", "export", export.ID, "error", err.Error())
+			}
+		}
+
+		if len(exports) < 100 {
+			return nil
+		}
+	}
+}
+
+func (app *application) writeDataExport(ctx context.Context, exportID, userID int64) (string, error) {
+	data, err := app.store.Exports.CollectUserData(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(app.config.export.dir, 0o700); err != nil {
+		return "", err
+	}
+
+	filePath := filepath.Join(app.config.export.dir, fmt.Sprintf("export-%d-%d.zip", userID, exportID))
+	f, err := os.Create(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", data.Profile},
+		{"posts.json", data.Posts},
+		{"comments.json", data.Comments},
+		{"followers.json", data.Followers},
+		{"following.json", data.Following},
+		{"invitations.json", data.Invitations},
+	}
+
+	zw := zip.NewWriter(f)
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return "", err
+		}
+
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return "", err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+
+	return filePath, nil
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"backendwithgo/internal/store"
+	"context"
+	"errors"
 	"net/http"
 
 	"github.com/go-playground/validator/v10"
@@ -12,18 +14,18 @@ import (
 // getUserFeedHandler godoc
 //
 //	@Summary		Fetches the user feed
-//	@Description	Fetches the user feed
+//	@Description	Fetches the posts of the user and the accounts they follow, the posts those accounts reposted and the posts with tags the user follows. feed_reason on each post tells why it is in the feed. Pass next_cursor as cursor to get the next page.
 //	@Tags			feed
 //	@Accept			json
 //	@Produce		json
 //	@Param			since	query		string	false	"Since"
 //	@Param			until	query		string	false	"Until"
 //	@Param			limit	query		int		false	"Limit"
-//	@Param			offset	query		int		false	"Offset"
+//	@Param			cursor	query		string	false	"next_cursor of the previous page"
 //	@Param			sort	query		string	false	"Sort"
 //	@Param			tags	query		string	false	"Tags"
 //	@Param			search	query		string	false	"Search"
-//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Success		200		{object}	store.FeedPage
 //	@Failure		400		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
@@ -47,15 +49,54 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if fq.Tags, err = store.NormalizeTags(fq.Tags); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+
 	ctx := r.Context()
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(99), fq)
+	page, err := app.store.Posts.GetUserFeed(ctx, user.ID, fq)
 	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badrequestresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	feed := page.Posts
+
+	if err := app.enrichPosts(ctx, user.ID, feed); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
-	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
 }
+
+// enrichPosts fills in what a page of posts is listed with: mentions, the
+// viewer's reactions, attachments and repost counts, and renders the content.
+func (app *application) enrichPosts(ctx context.Context, viewerID int64, posts []store.PostWithData) error {
+	if err := app.attachPostMentions(ctx, posts); err != nil {
+		return err
+	}
+	if err := app.attachPostReactions(ctx, viewerID, posts); err != nil {
+		return err
+	}
+	if err := app.attachPostAttachments(ctx, posts); err != nil {
+		return err
+	}
+	if err := app.attachRepostCounts(ctx, posts); err != nil {
+		return err
+	}
+
+	app.renderPosts(posts)
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// startBackgroundJobs starts the periodic jobs of the API. They stop when ctx
+// is cancelled.
+func (app *application) startBackgroundJobs(ctx context.Context) {
+	go app.runPeriodically(ctx, "suggestions", app.config.suggestions.refreshInterval, app.refreshStaleSuggestions)
+	go app.runPeriodically(ctx, "notification emails", app.config.notifications.emailBatchWindow, app.sendNotificationEmails)
+	go app.runPeriodically(ctx, "scheduled posts", app.config.posts.publishInterval, app.publishScheduledPosts)
+	go app.runPeriodically(ctx, "trash purge", app.config.posts.purgeInterval, app.purgeTrash)
+	go app.runPeriodically(ctx, "unused attachments", app.config.attachments.cleanInterval, app.deleteUnusedAttachments)
+	go app.runPeriodically(ctx, "data exports", app.config.export.buildInterval, app.buildDataExports)
+	go app.runPeriodically(ctx, "expired data exports", app.config.export.cleanInterval, app.deleteExpiredDataExports)
+}
+
+func (app *application) runPeriodically(ctx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				app.logger.Errorw("background job failed", "job", name, "error", err.Error())
+			}
+		}
+	}
+}
@@ -2,11 +2,16 @@ package main
 
 import (
 	"backendwithgo/internal/auth"
+	"backendwithgo/internal/blob"
 	"backendwithgo/internal/db"
 	"backendwithgo/internal/env"
+	"backendwithgo/internal/events"
 	"backendwithgo/internal/mailer"
+	"backendwithgo/internal/markdown"
 	"backendwithgo/internal/ratelimiter"
+	"backendwithgo/internal/search"
 	"backendwithgo/internal/store"
+	"context"
 	"expvar"
 	"runtime"
 	"time"
@@ -37,6 +42,7 @@ func main() {
 	cfg := config{
 		addr:        env.GetString("ADDR", ":8080"),
 		apiURL:      env.GetString("API_URL", "localhost:8080"),
+		externalURL: env.GetString("EXTERNAL_URL", "http://localhost:8080"),
 		frontendURL: env.GetString("FRONTEND_URL", "http://localhost:5173"),
 		db: dbConfig{
 			addr:         env.GetString("DB_ADDR", "niga:123456789@tcp(localhost:3306)/myapp?parseTime=true"),
@@ -68,6 +74,52 @@ func main() {
 			TimeFrame:            time.Second * 5,
 			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
 		},
+		suggestions: suggestionsConfig{
+			ttl:             time.Hour * 6,
+			refreshInterval: time.Minute,
+		},
+		export: exportConfig{
+			dir:           env.GetString("EXPORT_DIR", "./tmp/exports"),
+			linkExp:       time.Hour * 24 * 7, // 7 days
+			buildInterval: time.Second * 10,
+			cleanInterval: time.Hour,
+		},
+		notifications: notificationsConfig{
+			emailBatchWindow:   time.Minute * 2,
+			unsubscribeLinkExp: time.Hour * 24 * 60, // 60 days
+		},
+		comments: commentsConfig{
+			depth:           env.GetInt("COMMENTS_TREE_DEPTH", 3),
+			repliesPerLevel: env.GetInt("COMMENTS_REPLIES_PER_LEVEL", 3),
+			maxDepth:        env.GetInt("COMMENTS_MAX_DEPTH", 10),
+		},
+		posts: postsConfig{
+			publishInterval: time.Second * 30,
+			trashRetention:  time.Hour * 24 * time.Duration(env.GetInt("POSTS_TRASH_RETENTION_DAYS", 30)),
+			purgeInterval:   time.Hour,
+			renderCacheSize: env.GetInt("POSTS_RENDER_CACHE_SIZE", 10000),
+		},
+		attachments: attachmentsConfig{
+			dir:             env.GetString("ATTACHMENTS_DIR", "./tmp/attachments"),
+			maxPerPost:      env.GetInt("ATTACHMENTS_MAX_PER_POST", 4),
+			maxImageSize:    10 << 20, // 10 MB
+			maxVideoSize:    50 << 20, // 50 MB
+			allowVideo:      env.GetBool("ATTACHMENTS_ALLOW_VIDEO", true),
+			thumbnailSize:   320,
+			imageWorkers:    env.GetInt("ATTACHMENTS_IMAGE_WORKERS", 2),
+			transferTimeout: time.Minute * 10,
+			linkExp:         time.Minute * 15,
+			unusedTTL:       time.Hour * 24,
+			cleanInterval:   time.Hour,
+		},
+		search: searchConfig{
+			engine:         env.GetString("SEARCH_ENGINE", "mysql"),
+			memoryMaxPosts: env.GetInt("SEARCH_MEMORY_MAX_POSTS", 100000),
+			singleInstance: env.GetBool("SEARCH_SINGLE_INSTANCE", false),
+		},
+		reactions: reactionsConfig{
+			emoji: env.GetStrings("REACTIONS_EMOJI", []string{"👍", "❤️", "😂", "😮", "😢", "😡"}),
+		},
 
 	}
 
@@ -95,6 +147,22 @@ func main() {
 	)	
 	store := store.NewSQL(datab)
 
+	// index trong bộ nhớ chỉ dùng khi chạy một instance; không nạp được thì
+	// dùng FULLTEXT của MySQL
+	var searchEngine search.Engine = search.NewMySQL(store.Search)
+	if cfg.search.engine == "memory" && !cfg.search.singleInstance {
+		logger.Errorw("in-memory search index needs SEARCH_SINGLE_INSTANCE=true, falling back to mysql")
+	} else if cfg.search.engine == "memory" {
+		logger.Warnw("in-memory search index only sees writes of this instance; do not run several instances")
+		index := search.NewMemory()
+		if n, err := index.Load(context.Background(), store.Search, cfg.search.memoryMaxPosts); err != nil {
+			logger.Errorw("error loading search index, falling back to mysql", "error", err.Error())
+		} else {
+			logger.Infow("search index loaded", "posts", n)
+			searchEngine = index
+		}
+	}
+
 	mailer := mailer.NewSendgrid(cfg.mail.sendGrid.apiKey, cfg.mail.fromEmail)
 
 	jwtAuthenticator := auth.NewJWTAuthenticator(
@@ -110,6 +178,14 @@ func main() {
 		mailer: mailer,
 		authenticator: jwtAuthenticator,
 		rateLimiter:   rateLimiter,
+		signer:        auth.NewSigner(cfg.auth.token.secret),
+		events:        events.NewInMemoryHub(256, 5*time.Minute),
+		streamsDone:   make(chan struct{}),
+
+		markdown:   markdown.NewCache(cfg.posts.renderCacheSize),
+		blobs:      blob.NewLocal(cfg.attachments.dir),
+		search:     searchEngine,
+		imageSlots: make(chan struct{}, max(cfg.attachments.imageWorkers, 1)),
 	}
 
 	// Metrics collected
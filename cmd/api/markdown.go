@@ -0,0 +1,64 @@
+package main
+
+import (
+	"backendwithgo/internal/markdown"
+	"backendwithgo/internal/store"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type PreviewPostPayload struct {
+	Content string `json:"content" validate:"required,max=100000"`
+}
+
+type PreviewPostResponse struct {
+	ContentHTML string `json:"content_html"`
+}
+
+// PreviewPost godoc
+//
+//	@Summary		Previews post content
+//	@Description	Renders Markdown content to the sanitized HTML a post with that content would get, without saving anything
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		PreviewPostPayload	true	"Post content"
+//	@Success		200		{object}	PreviewPostResponse
+//	@Failure		400		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/preview [post]
+func (app *application) previewPostHandler(w http.ResponseWriter, r *http.Request) {
+	var payload PreviewPostPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	var Validate = validator.New()
+	if err := Validate.Struct(payload); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	res := PreviewPostResponse{ContentHTML: markdown.Render(payload.Content)}
+	if err := app.jsonResponse(w, http.StatusOK, res); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// renderPost sets the HTML rendering of the content of a post and of the post
+// it quotes.
+func (app *application) renderPost(post *store.Post) {
+	post.ContentHTML = app.markdown.Render(post.ID, post.Version, post.Content)
+	if post.QuotedPost != nil {
+		app.renderPost(post.QuotedPost)
+	}
+}
+
+// renderPosts renders the content of a page of posts.
+func (app *application) renderPosts(posts []store.PostWithData) {
+	for i := range posts {
+		app.renderPost(&posts[i].Post)
+	}
+}
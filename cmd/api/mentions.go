@@ -0,0 +1,115 @@
+package main
+
+import (
+	"backendwithgo/internal/store"
+	"context"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// GetMentions godoc
+//
+//	@Summary		Lists posts mentioning the user
+//	@Description	Lists the posts whose content mentions the authenticated user, newest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.PostWithData
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mentions [get]
+func (app *application) getMentionsHandler(w http.ResponseWriter, r *http.Request) {
+	var validate = validator.New()
+	fq := store.PaginationQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(fq); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	posts, err := app.store.Mentions.GetPostsMentioning(ctx, user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.enrichPosts(ctx, user.ID, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// attachPostMentions loads the mention entities of a page of posts.
+func (app *application) attachPostMentions(ctx context.Context, posts []store.PostWithData) error {
+	ids := make([]int64, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+
+	mentions, err := app.store.Mentions.GetForPosts(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].Mentions = mentionsOrEmpty(mentions[posts[i].ID])
+	}
+
+	return nil
+}
+
+// attachCommentMentions loads the mention entities of a list of comments and
+// of their loaded replies.
+func (app *application) attachCommentMentions(ctx context.Context, comments []store.Comment) error {
+	ids := collectCommentIDs(comments, nil)
+
+	mentions, err := app.store.Mentions.GetForComments(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	setCommentMentions(comments, mentions)
+
+	return nil
+}
+
+func collectCommentIDs(comments []store.Comment, ids []int64) []int64 {
+	for _, c := range comments {
+		ids = append(ids, c.ID)
+		ids = collectCommentIDs(c.Replies, ids)
+	}
+	return ids
+}
+
+func setCommentMentions(comments []store.Comment, mentions map[int64][]store.Mention) {
+	for i := range comments {
+		comments[i].Mentions = mentionsOrEmpty(mentions[comments[i].ID])
+		setCommentMentions(comments[i].Replies, mentions)
+	}
+}
+
+func mentionsOrEmpty(mentions []store.Mention) []store.Mention {
+	if mentions == nil {
+		return []store.Mention{}
+	}
+	return mentions
+}
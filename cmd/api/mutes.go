@@ -0,0 +1,161 @@
+package main
+
+import (
+	"backendwithgo/internal/store"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type CreateMutePayload struct {
+	Kind      string     `json:"kind" validate:"required,oneof=user tag keyword"`
+	UserID    int64      `json:"user_id" validate:"required_if=Kind user"`
+	Value     string     `json:"value" validate:"required_unless=Kind user,max=100"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// GetMutes godoc
+//
+//	@Summary		Lists the mute rules
+//	@Description	Lists the active mute rules of the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.Mute
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mutes [get]
+func (app *application) getMutesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserfromContext(r)
+
+	mutes, err := app.store.Mutes.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, mutes); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CreateMute godoc
+//
+//	@Summary		Mutes a user, tag or keyword
+//	@Description	Hides posts of a user, with a tag or containing a keyword from the feed, optionally until expires_at
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateMutePayload	true	"Mute payload"
+//	@Success		201		{object}	store.Mute
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mutes [post]
+func (app *application) createMuteHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateMutePayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	// keyword chỉ có khoảng trắng sẽ khớp mọi bài viết
+	payload.Value = strings.TrimSpace(payload.Value)
+
+	var Validate = validator.New()
+	if err := Validate.Struct(payload); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		app.badrequestresponse(w, r, errors.New("expires_at must be in the future"))
+		return
+	}
+
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	mute := &store.Mute{
+		UserID:    user.ID,
+		Kind:      payload.Kind,
+		ExpiresAt: payload.ExpiresAt,
+	}
+
+	if payload.Kind == store.MuteKindUser {
+		if payload.UserID == user.ID {
+			app.badrequestresponse(w, r, errors.New("you cannot mute yourself"))
+			return
+		}
+
+		if _, err := app.store.Users.GetByID(ctx, payload.UserID); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				app.notfoundresponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		mute.TargetUserID = payload.UserID
+	} else if payload.Kind == store.MuteKindTag {
+		// tag bị mute phải khớp đúng tên tag đã chuẩn hoá
+		tag, err := store.NormalizeTag(payload.Value)
+		if err != nil {
+			app.badrequestresponse(w, r, err)
+			return
+		}
+		mute.Value = tag
+	} else {
+		mute.Value = payload.Value
+	}
+
+	if err := app.store.Mutes.Create(ctx, mute); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, mute); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteMute godoc
+//
+//	@Summary		Removes a mute rule
+//	@Description	Removes a mute rule of the authenticated user by ID
+//	@Tags			users
+//	@Produce		json
+//	@Param			muteID	path		int	true	"Mute ID"
+//	@Success		204		{string}	string
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mutes/{muteID} [delete]
+func (app *application) deleteMuteHandler(w http.ResponseWriter, r *http.Request) {
+	muteID, err := strconv.ParseInt(chi.URLParam(r, "muteID"), 10, 64)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+
+	if err := app.store.Mutes.Delete(r.Context(), user.ID, muteID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"backendwithgo/internal/mailer"
+	"backendwithgo/internal/store"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// sendNotificationEmails sends one email per user with the notifications
+// queued since the last run. The queue is kept in the notifications table, so
+// nothing is lost on restart and each notification is emailed by one API
+// instance only.
+func (app *application) sendNotificationEmails(ctx context.Context) error {
+	for {
+		userID, notifications, err := app.store.Notifications.ClaimEmails(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		if len(notifications) == 0 {
+			continue
+		}
+
+		app.sendNotificationEmail(ctx, userID, notifications)
+	}
+}
+
+func (app *application) sendNotificationEmail(ctx context.Context, userID int64, notifications []store.Notification) {
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		app.logger.Errorw("error loading user for notification email", "user", userID, "error", err.Error())
+		return
+	}
+
+	items := make([]string, 0, len(notifications))
+	for _, n := range notifications {
+		items = append(items, describeNotification(n))
+	}
+
+	id := strconv.FormatInt(user.ID, 10)
+	expires := strconv.FormatInt(time.Now().Add(app.config.notifications.unsubscribeLinkExp).Unix(), 10)
+	unsubscribeURL := fmt.Sprintf("%s/v1/notifications/unsubscribe?user=%s&expires=%s&signature=%s",
+		app.config.externalURL, id, expires, app.signer.Sign("unsubscribe", id, expires))
+
+	isProdEnv := app.config.env == "production"
+	vars := notificationDigest{
+		Username:       user.Username,
+		Items:          items,
+		AppURL:         app.config.frontendURL,
+		UnsubscribeURL: unsubscribeURL,
+	}
+
+	status, err := app.mailer.Send(mailer.NotificationDigestTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		app.logger.Errorw("error sending notification email", "user", userID, "error", err.Error())
+		return
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+}
+
+type notificationDigest struct {
+	Username       string
+	Items          []string
+	AppURL         string
+	UnsubscribeURL string
+}
+
+// Headers lets mail clients offer one-click unsubscribe (RFC 8058): they
+// POST to the link, which the GET of a browser only confirms.
+func (d notificationDigest) Headers() map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + d.UnsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+func describeNotification(n store.Notification) string {
+	actor := n.Actor.Username
+	switch {
+	case n.ActorCount == 2:
+		actor += " and 1 other"
+	case n.ActorCount > 2:
+		actor += fmt.Sprintf(" and %d others", n.ActorCount-1)
+	}
+
+	switch n.Type {
+	case store.NotificationFollow:
+		return actor + " followed you"
+	case store.NotificationFollowRequest:
+		return actor + " asked to follow you"
+	case store.NotificationComment:
+		return actor + " commented on your post"
+	case store.NotificationMention:
+		return actor + " mentioned you"
+	default:
+		return actor + " interacted with you"
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"backendwithgo/internal/events"
+	"backendwithgo/internal/store"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type NotificationsResponse struct {
+	Notifications []store.Notification `json:"notifications"`
+	UnreadCount   int                  `json:"unread_count"`
+}
+
+// GetNotifications godoc
+//
+//	@Summary		Lists notifications
+//	@Description	Lists the notifications of the authenticated user with the unread count
+//	@Tags			notifications
+//	@Produce		json
+//	@Param			unread	query		bool	false	"Only unread notifications"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	NotificationsResponse
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications [get]
+func (app *application) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	var validate = validator.New()
+	fq := store.PaginationQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(fq); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	unreadOnly := false
+	if unread := r.URL.Query().Get("unread"); unread != "" {
+		unreadOnly, err = strconv.ParseBool(unread)
+		if err != nil {
+			app.badrequestresponse(w, r, err)
+			return
+		}
+	}
+
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	notifications, err := app.store.Notifications.GetByUserID(ctx, user.ID, unreadOnly, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	unreadCount, err := app.store.Notifications.UnreadCount(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	res := NotificationsResponse{
+		Notifications: notifications,
+		UnreadCount:   unreadCount,
+	}
+	if err := app.jsonResponse(w, http.StatusOK, res); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// MarkNotificationRead godoc
+//
+//	@Summary		Marks a notification as read
+//	@Description	Marks a notification of the authenticated user as read
+//	@Tags			notifications
+//	@Produce		json
+//	@Param			notificationID	path		int		true	"Notification ID"
+//	@Success		204				{string}	string	"Notification read"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/{notificationID}/read [put]
+func (app *application) markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	notificationID, err := strconv.ParseInt(chi.URLParam(r, "notificationID"), 10, 64)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+
+	if err := app.store.Notifications.MarkRead(r.Context(), user.ID, notificationID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllNotificationsRead godoc
+//
+//	@Summary		Marks all notifications as read
+//	@Description	Marks every notification of the authenticated user as read
+//	@Tags			notifications
+//	@Produce		json
+//	@Success		204	{string}	string	"Notifications read"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/read-all [put]
+func (app *application) markAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserfromContext(r)
+
+	if err := app.store.Notifications.MarkAllRead(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notify records a notification event and delivers it according to the
+// preferences of the recipient. Notifications are a side effect of the
+// request, so failures are logged instead of failing the request. Users are
+// never notified about their own actions, nor about those of users they block
+// or are blocked by.
+func (app *application) notify(ctx context.Context, n *store.Notification) {
+	if n.ActorID == n.UserID {
+		return
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, n.UserID, n.ActorID)
+	if err != nil {
+		app.logger.Errorw("error checking blocks", "user", n.UserID, "error", err.Error())
+		return
+	}
+	if blocked {
+		return
+	}
+
+	channel, err := app.store.NotificationPreferences.GetChannel(ctx, n.UserID, n.Type)
+	if err != nil {
+		app.logger.Errorw("error loading notification preferences", "user", n.UserID, "error", err.Error())
+		return
+	}
+	if channel == store.ChannelOff {
+		return
+	}
+
+	if err := app.store.Notifications.Create(ctx, n); err != nil {
+		app.logger.Errorw("error creating notification", "type", n.Type, "user", n.UserID, "error", err.Error())
+		return
+	}
+
+	app.publish(ctx, []int64{n.UserID}, events.Notification, n)
+
+	if channel == store.ChannelEmail {
+		if err := app.store.Notifications.QueueEmail(ctx, n.ID); err != nil {
+			app.logger.Errorw("error queueing notification email", "notification", n.ID, "error", err.Error())
+		}
+	}
+}
+
+// GetNotificationPreferences godoc
+//
+//	@Summary		Fetches notification preferences
+//	@Description	Fetches the delivery channel (in_app, email or off) of every notification type
+//	@Tags			notifications
+//	@Produce		json
+//	@Success		200	{object}	store.NotificationPreferences
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/preferences [get]
+func (app *application) getNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserfromContext(r)
+
+	prefs, err := app.store.NotificationPreferences.Get(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, prefs); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateNotificationPreferences godoc
+//
+//	@Summary		Updates notification preferences
+//	@Description	Sets the delivery channel (in_app, email or off) of one or more notification types
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		store.NotificationPreferences	true	"Channel by notification type"
+//	@Success		200		{object}	store.NotificationPreferences
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/preferences [put]
+func (app *application) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	var payload store.NotificationPreferences
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	if len(payload) == 0 {
+		app.badrequestresponse(w, r, errors.New("no preferences given"))
+		return
+	}
+
+	for t, channel := range payload {
+		if !slices.Contains(store.NotificationTypes, t) {
+			app.badrequestresponse(w, r, fmt.Errorf("unknown notification type %q", t))
+			return
+		}
+		if channel != store.ChannelInApp && channel != store.ChannelEmail && channel != store.ChannelOff {
+			app.badrequestresponse(w, r, fmt.Errorf("channel of %q must be one of in_app, email, off", t))
+			return
+		}
+	}
+
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	if err := app.store.NotificationPreferences.Set(ctx, user.ID, payload); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	prefs, err := app.store.NotificationPreferences.Get(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, prefs); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// unsubscribePage is shown by the unsubscribe link of notification emails.
+// Opening the link only shows the form: mail scanners that follow links must
+// not unsubscribe anybody.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Notification emails</title></head>
+<body>
+{{if .Done}}
+<p>You will no longer receive notification emails. Notifications stay available in the app.</p>
+{{else}}
+<form method="post" action="{{.Action}}">
+<p>Stop receiving notification emails?</p>
+<button type="submit">Unsubscribe</button>
+</form>
+{{end}}
+</body>
+</html>`))
+
+// ConfirmUnsubscribeNotificationEmails godoc
+//
+//	@Summary		Confirms unsubscribing from notification emails
+//	@Description	Unsubscribe link of notification emails. Shows a form that POSTs to the same link; opening the link changes nothing.
+//	@Tags			notifications
+//	@Produce		html
+//	@Param			user		query		int		true	"User ID"
+//	@Param			expires		query		int		true	"Link expiry (unix time)"
+//	@Param			signature	query		string	true	"Link signature"
+//	@Success		200			{string}	string	"Confirmation page"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Router			/notifications/unsubscribe [get]
+func (app *application) confirmUnsubscribeNotificationEmailsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := app.verifyUnsubscribeLink(w, r); !ok {
+		return
+	}
+
+	app.renderUnsubscribePage(w, r, false)
+}
+
+// UnsubscribeNotificationEmails godoc
+//
+//	@Summary		Unsubscribes from notification emails
+//	@Description	One-click unsubscribe (RFC 8058) of notification emails, also posted by the confirmation form. Every type delivered by email falls back to in-app.
+//	@Tags			notifications
+//	@Produce		html
+//	@Param			user		query		int		true	"User ID"
+//	@Param			expires		query		int		true	"Link expiry (unix time)"
+//	@Param			signature	query		string	true	"Link signature"
+//	@Success		200			{string}	string	"Unsubscribed page"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/notifications/unsubscribe [post]
+func (app *application) unsubscribeNotificationEmailsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.verifyUnsubscribeLink(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.store.NotificationPreferences.UnsubscribeEmail(r.Context(), userID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.renderUnsubscribePage(w, r, true)
+}
+
+// verifyUnsubscribeLink checks the signature and expiry of an unsubscribe
+// link and returns the user it was sent to. It answers the request itself
+// when the link is not valid.
+func (app *application) verifyUnsubscribeLink(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userParam := r.URL.Query().Get("user")
+	userID, err := strconv.ParseInt(userParam, 10, 64)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return 0, false
+	}
+
+	expiresParam := r.URL.Query().Get("expires")
+	expires, err := strconv.ParseInt(expiresParam, 10, 64)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return 0, false
+	}
+
+	signature := r.URL.Query().Get("signature")
+	if !app.signer.Verify(signature, "unsubscribe", userParam, expiresParam) || time.Now().Unix() > expires {
+		app.forbiddenResponse(w, r)
+		return 0, false
+	}
+
+	return userID, true
+}
+
+func (app *application) renderUnsubscribePage(w http.ResponseWriter, r *http.Request, done bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	data := struct {
+		Done   bool
+		Action string
+	}{
+		Done:   done,
+		Action: r.URL.RequestURI(),
+	}
+	if err := unsubscribePage.Execute(w, data); err != nil {
+		app.logger.Errorw("error rendering unsubscribe page", "error", err.Error())
+	}
+}
+
+// notifyMentions notifies users newly mentioned in a post or in one of its
+// comments. Users that may not read the post are left out, so that the
+// notification does not reveal it.
+func (app *application) notifyMentions(ctx context.Context, actorID int64, post *store.Post, userIDs []int64) {
+	for _, userID := range userIDs {
+		visible, err := app.canViewPost(ctx, &store.User{ID: userID}, post)
+		if err != nil {
+			app.logger.Errorw("error checking post visibility", "post", post.ID, "user", userID, "error", err.Error())
+			continue
+		}
+		if !visible {
+			continue
+		}
+
+		app.notify(ctx, &store.Notification{
+			UserID:     userID,
+			Type:       store.NotificationMention,
+			TargetType: store.TargetPost,
+			TargetID:   post.ID,
+			ActorID:    actorID,
+		})
+	}
+}
+
+// notifyComment notifies the author of a post about a new comment on it.
+func (app *application) notifyComment(ctx context.Context, post *store.Post, comment *store.Comment) {
+	app.notify(ctx, &store.Notification{
+		UserID:     post.UserID,
+		Type:       store.NotificationComment,
+		TargetType: store.TargetPost,
+		TargetID:   post.ID,
+		ActorID:    comment.UserID,
+	})
+}
+
+// publishCommentEvent streams a comment event to the author of the post.
+func (app *application) publishCommentEvent(ctx context.Context, post *store.Post, eventType string, comment *store.Comment) {
+	if comment.UserID == post.UserID {
+		return
+	}
+
+	app.publish(ctx, []int64{post.UserID}, eventType, comment)
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"backendwithgo/internal/events"
 	"backendwithgo/internal/store"
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
@@ -17,15 +22,21 @@ type postKey string
 const postCtxKey postKey = "post"
 
 type CreatePostPayload struct {
-	Title   string   `json:"title"`
-	Content string   `json:"content"`
-	Tags    []string `json:"tags"`
+	Title        string     `json:"title" validate:"max=255"`
+	Content      string     `json:"content" validate:"max=100000"`
+	Tags         []string   `json:"tags"`
+	QuotedPostID *int64     `json:"quoted_post_id"`
+	Status       string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt    *time.Time `json:"publish_at" validate:"required_if=Status scheduled"`
+	Visibility   string     `json:"visibility" validate:"omitempty,oneof=public followers mentioned private"`
+	// AttachmentIDs are uploads of the author, in the order to show them.
+	AttachmentIDs []int64 `json:"attachment_ids"`
 }
 
 // CreatePost godoc
 //
 //	@Summary		Creates a post
-//	@Description	Creates a post
+//	@Description	Creates a post, or a quote post of another post when quoted_post_id is set. The post is published unless status is draft, or scheduled with a publish_at time. Visibility is public (default), followers, mentioned or private. Content is Markdown and is returned rendered as content_html. Tags are normalized: a leading # is dropped and letters are lowercased.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -49,21 +60,108 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if payload.Status == "" {
+		payload.Status = store.PostStatusPublished
+	}
+
+	if payload.Visibility == "" {
+		payload.Visibility = store.PostVisibilityPublic
+	}
+
+	if payload.Status == store.PostStatusScheduled {
+		if !payload.PublishAt.After(time.Now()) {
+			app.badrequestresponse(w, r, errors.New("publish_at must be in the future"))
+			return
+		}
+	} else if payload.PublishAt != nil {
+		app.badrequestresponse(w, r, errors.New("publish_at is only allowed on scheduled posts"))
+		return
+	}
+
+	if len(payload.AttachmentIDs) > app.config.attachments.maxPerPost {
+		app.badrequestresponse(w, r, fmt.Errorf("a post can have at most %d attachments", app.config.attachments.maxPerPost))
+		return
+	}
+
+	tags, err := normalizePostTags(payload.Tags)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	attachments := make([]store.Attachment, 0, len(payload.AttachmentIDs))
+	for i, id := range payload.AttachmentIDs {
+		if slices.Contains(payload.AttachmentIDs[:i], id) {
+			app.badrequestresponse(w, r, fmt.Errorf("attachment %d is listed twice", id))
+			return
+		}
+		attachments = append(attachments, store.Attachment{ID: id})
+	}
+
 	user := app.getUserfromContext(r)
 
 	post := &store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserID:  user.ID,
+		Title:       payload.Title,
+		Content:     payload.Content,
+		Tags:        tags,
+		UserID:      user.ID,
+		User:        store.User{ID: user.ID, Username: user.Username, IsPrivate: user.IsPrivate},
+		Status:      payload.Status,
+		PublishAt:   payload.PublishAt,
+		Visibility:  payload.Visibility,
+		Attachments: attachments,
 	}
 
 	ctx := r.Context()
 
+	if payload.QuotedPostID != nil {
+		quoted, err := app.loadQuotedPost(ctx, user, *payload.QuotedPostID)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				app.badrequestresponse(w, r, errors.New("quoted post not found"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		post.QuotedPostID = &quoted.ID
+		post.QuotedPost = quoted
+	}
+
 	if err := app.store.Posts.Create(ctx, post); err != nil {
-		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		switch {
+		case errors.Is(err, store.ErrInvalidAttachment):
+			app.badrequestresponse(w, r, err)
+		default:
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
+
+	app.indexPost(ctx, post)
+
+	if err := app.loadAttachments(ctx, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	mentions, mentioned, err := app.store.Mentions.Sync(ctx, post.ID, nil, post.Content)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	post.Mentions = mentions
+
+	// bản nháp và bài hẹn giờ chỉ thông báo khi được đăng
+	if post.Status == store.PostStatusPublished {
+		if notifiesMentions(post) {
+			app.notifyMentions(ctx, user.ID, post, mentioned)
+		}
+		app.publishNewPost(ctx, post)
+	}
+
+	app.renderPost(post)
 	if err := WriteJSON(w, http.StatusCreated, post); err != nil {
 		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -77,24 +175,80 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Post ID"
-//	@Success		200	{object}	store.Post
-//	@Failure		404	{object}	error
-//	@Failure		500	{object}	error
+//	@Param			id		path		int		true	"Post ID"
+//	@Param			sort	query		string	false	"Comment order: newest (default), oldest or top"
+//	@Param			limit	query		int		false	"Comments to embed"
+//	@Param			depth	query		int		false	"Levels of comment replies to load"
+//	@Param			replies	query		int		false	"Replies to load per comment and level"
+//	@Success		200		{object}	store.PostWithData
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [get]
 func (app *application) GetPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getpostCtx(r)
 	ctx := r.Context()
-	comments, err := app.store.Comments.GetByPostID(ctx, post.ID)
+
+	page, err := app.getCommentPage(w, r, post.ID)
 	if err != nil {
+		return
+	}
+
+	post.Comments = page.Comments
+	post.CommentsTotal = page.Total
+	post.CommentsCursor = page.NextCursor
+
+	postMentions, err := app.store.Mentions.GetForPosts(ctx, []int64{post.ID})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	post.Mentions = mentionsOrEmpty(postMentions[post.ID])
+
+	if post.QuotedPostID != nil {
+		quoted, err := app.store.Posts.GetByID(ctx, *post.QuotedPostID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if quoted != nil {
+			visible, err := app.canViewPost(ctx, app.getUserfromContext(r), quoted)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+			if visible {
+				post.QuotedPost = quoted
+			}
+		}
+	}
+
+	attached := []*store.Post{post}
+	if post.QuotedPost != nil {
+		attached = append(attached, post.QuotedPost)
+	}
+	if err := app.loadAttachments(ctx, attached...); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	post.Comments = comments
+	w.Header().Set("ETag", postETag(post))
+	app.renderPost(post)
+
+	res := []store.PostWithData{{Post: *post, CommentCount: page.Total}}
+	if err := app.attachPostReactions(ctx, app.getUserfromContext(r).ID, res); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
 
-	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+	if err := app.attachRepostCounts(ctx, res); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, res[0]); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -103,7 +257,7 @@ func (app *application) GetPostHandler(w http.ResponseWriter, r *http.Request) {
 // DeletePost godoc
 //
 //	@Summary		Deletes a post
-//	@Description	Delete a post by ID
+//	@Description	Moves a post to the trash. It can be restored until the trash is purged.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -128,35 +282,57 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 		default:
 			app.internalServerError(w, r, err)
 		}
-
-		w.WriteHeader(http.StatusNoContent)
+		return
 	}
+
+	app.unindexPost(ctx, id)
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 type UpdatePostPayload struct {
-	Title   *string `json:"title"`
-	Content *string `json:"content"`
+	Title      *string `json:"title" validate:"omitempty,max=255"`
+	Content    *string `json:"content" validate:"omitempty,max=100000"`
+	Visibility *string `json:"visibility" validate:"omitempty,oneof=public followers mentioned private"`
+	// Tags replaces all the tags of the post when set.
+	Tags *[]string `json:"tags"`
 }
 
 // UpdatePost godoc
 //
 //	@Summary		Updates a post
-//	@Description	Updates a post by ID
+//	@Description	Updates a post by ID. If-Match must carry the ETag of the version being edited. Tags, when set, replace all the tags of the post.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id		path		int					true	"Post ID"
-//	@Param			payload	body		UpdatePostPayload	true	"Post payload"
-//	@Success		200		{object}	store.Post
-//	@Failure		400		{object}	error
-//	@Failure		401		{object}	error
-//	@Failure		404		{object}	error
-//	@Failure		500		{object}	error
+//	@Param			id			path		int					true	"Post ID"
+//	@Param			If-Match	header		string				true	"ETag of the edited version"
+//	@Param			payload		body		UpdatePostPayload	true	"Post payload"
+//	@Success		200			{object}	store.PostWithData
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		412			{object}	error
+//	@Failure		428			{object}	error
+//	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [patch]
 func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getpostCtx(r)
 
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		app.preconditionRequiredResponse(w, r, errors.New("If-Match header is required"))
+		return
+	}
+
+	if !etagMatches(ifMatch, postETag(post)) {
+		app.renderPost(post)
+		app.editConflictResponse(w, r, http.StatusPreconditionFailed, postETag(post), post)
+		return
+	}
+
 	var payload UpdatePostPayload
 	if err := ReadJSON(w, r, &payload); err != nil {
 		app.badrequestresponse(w, r, err)
@@ -176,11 +352,51 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		post.Title = *payload.Title
 	}
 
-	if err := app.store.Posts.Update(r.Context(), post); err != nil {
+	if payload.Visibility != nil {
+		post.Visibility = *payload.Visibility
+	}
+
+	if payload.Tags != nil {
+		tags, err := normalizePostTags(*payload.Tags)
+		if err != nil {
+			app.badrequestresponse(w, r, err)
+			return
+		}
+		post.Tags = tags
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Posts.Update(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrEditConflict):
+			app.postEditConflict(w, r, post.ID)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.indexPost(ctx, post)
+
+	mentions, mentioned, err := app.store.Mentions.Sync(ctx, post.ID, nil, post.Content)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	post.Mentions = mentions
+	// người nhắc đến là tác giả, kể cả khi moderator sửa bài
+	if notifiesMentions(post) {
+		app.notifyMentions(ctx, post.UserID, post, mentioned)
+	}
+
+	if err := app.loadAttachments(ctx, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", postETag(post))
+	app.renderPost(post)
 	if err := WriteJSON(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -207,11 +423,145 @@ func (app *application) postcontextMiddleware(next http.Handler) http.Handler {
 
 			return
 		}
+
+		// bài không được xem thì trả 404 để không lộ sự tồn tại của bài,
+		// trừ moderator cần xem để kiểm duyệt
+		user := app.getUserfromContext(r)
+		allowed, err := app.canViewPost(ctx, user, post)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !allowed {
+			allowed, err = app.checkRolePrecedence(ctx, user, "moderator")
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+		if !allowed {
+			app.notfoundresponse(w, r, errors.New("post not found"))
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtxKey, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// publishNewPost streams a new post to the feeds of the author's followers,
+// leaving out those whose mutes hide it from their feed. Posts meant for the
+// mentioned users or the author only are not streamed.
+func (app *application) publishNewPost(ctx context.Context, post *store.Post) {
+	if post.Visibility == store.PostVisibilityMentioned || post.Visibility == store.PostVisibilityPrivate {
+		return
+	}
+
+	app.renderPost(post)
+
+	followerIDs, err := app.store.Followers.GetFollowerIDs(ctx, post.UserID)
+	if err != nil {
+		app.logger.Errorw("error loading followers", "user", post.UserID, "error", err.Error())
+		return
+	}
+
+	followerIDs, err = app.store.Mutes.FilterMuting(ctx, post.ID, followerIDs)
+	if err != nil {
+		app.logger.Errorw("error applying mutes", "post", post.ID, "error", err.Error())
+		return
+	}
+
+	app.publish(ctx, followerIDs, events.PostCreated, post)
+}
+
+// canViewPost reports whether the user may read the post. It is the
+// counterpart of the visibility filter used by the post listings: unpublished
+// and private posts are only shown to their author, mentioned posts to the
+// users they mention, followers posts to approved followers, and public posts
+// to everyone unless the account is private. Users blocking or blocked by the
+// author see none of their posts.
+func (app *application) canViewPost(ctx context.Context, user *store.User, post *store.Post) (bool, error) {
+	if post.UserID == user.ID {
+		return true, nil
+	}
+
+	if post.Status != store.PostStatusPublished {
+		return false, nil
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, user.ID, post.UserID)
+	if err != nil {
+		return false, err
+	}
+	if blocked {
+		return false, nil
+	}
+
+	switch post.Visibility {
+	case store.PostVisibilityPublic:
+		if !post.User.IsPrivate {
+			return true, nil
+		}
+	case store.PostVisibilityFollowers:
+	case store.PostVisibilityMentioned:
+		mentions, err := app.store.Mentions.GetForPosts(ctx, []int64{post.ID})
+		if err != nil {
+			return false, err
+		}
+		for _, m := range mentions[post.ID] {
+			if m.UserID == user.ID {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, nil
+	}
+
+	return app.store.Followers.IsFollowing(ctx, user.ID, post.UserID)
+}
+
+// notifiesMentions reports whether the users mentioned in a post are told
+// about it: only once it is published, and never for private posts.
+func notifiesMentions(post *store.Post) bool {
+	return post.Status == store.PostStatusPublished && post.Visibility != store.PostVisibilityPrivate
+}
+
+// postETag identifies the version of a post for conditional updates.
+func postETag(post *store.Post) string {
+	return fmt.Sprintf(`"%d-%d"`, post.ID, post.Version)
+}
+
+// etagMatches reports whether an If-Match header value names the ETag. If-Match
+// uses the strong comparison of RFC 9110: weak tags never match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// postEditConflict answers an update that lost the race against another
+// one with the post as it is now.
+func (app *application) postEditConflict(w http.ResponseWriter, r *http.Request, postID int64) {
+	current, err := app.store.Posts.GetByID(r.Context(), postID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.renderPost(current)
+	app.editConflictResponse(w, r, http.StatusConflict, postETag(current), current)
+}
+
 func getpostCtx(r *http.Request) *store.Post {
 	post, _ := r.Context().Value(postCtxKey).(*store.Post)
 	return post
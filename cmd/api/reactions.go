@@ -0,0 +1,239 @@
+package main
+
+import (
+	"backendwithgo/internal/store"
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type ReactionPayload struct {
+	Emoji string `json:"emoji" validate:"required"`
+}
+
+type reactionResponse struct {
+	Reacted   bool            `json:"reacted"`
+	Reactions store.Reactions `json:"reactions"`
+}
+
+// TogglePostReaction godoc
+//
+//	@Summary		Reacts to a post
+//	@Description	Adds the reaction of the authenticated user to a post, or removes it if it is already there
+//	@Tags			reactions
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		ReactionPayload	true	"Reaction payload"
+//	@Success		200		{object}	reactionResponse
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions [post]
+func (app *application) togglePostReactionHandler(w http.ResponseWriter, r *http.Request) {
+	emoji, err := app.readReaction(w, r)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	post := getpostCtx(r)
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	reacted, err := app.store.Reactions.Toggle(ctx, user.ID, post.ID, nil, emoji)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, errors.New("reaction changed concurrently, try again"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	reactions, err := app.store.Reactions.GetForPosts(ctx, user.ID, []int64{post.ID})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	res := reactionResponse{Reacted: reacted, Reactions: reactions[post.ID]}
+	if err := app.jsonResponse(w, http.StatusOK, res); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ToggleCommentReaction godoc
+//
+//	@Summary		Reacts to a comment
+//	@Description	Adds the reaction of the authenticated user to a comment, or removes it if it is already there
+//	@Tags			reactions
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int				true	"Post ID"
+//	@Param			commentID	path		int				true	"Comment ID"
+//	@Param			payload		body		ReactionPayload	true	"Reaction payload"
+//	@Success		200			{object}	reactionResponse
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID}/reactions [post]
+func (app *application) toggleCommentReactionHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentCtx(r)
+	if comment.IsDeleted {
+		app.notfoundresponse(w, r, errors.New("comment was deleted"))
+		return
+	}
+
+	emoji, err := app.readReaction(w, r)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	reacted, err := app.store.Reactions.Toggle(ctx, user.ID, comment.PostID, &comment.ID, emoji)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, errors.New("reaction changed concurrently, try again"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	reactions, err := app.store.Reactions.GetForComments(ctx, user.ID, []int64{comment.ID})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	res := reactionResponse{Reacted: reacted, Reactions: reactions[comment.ID]}
+	if err := app.jsonResponse(w, http.StatusOK, res); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetPostReactors godoc
+//
+//	@Summary		Lists who reacted to a post
+//	@Description	Lists the users that reacted to a post, most recent first
+//	@Tags			reactions
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			emoji	query		string	false	"Only reactions with this emoji"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.Reactor
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions [get]
+func (app *application) getPostReactorsHandler(w http.ResponseWriter, r *http.Request) {
+	var validate = validator.New()
+	fq := store.PaginationQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(fq); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	emoji := r.URL.Query().Get("emoji")
+	if emoji != "" && !slices.Contains(app.config.reactions.emoji, emoji) {
+		app.badrequestresponse(w, r, errors.New("unsupported reaction"))
+		return
+	}
+
+	post := getpostCtx(r)
+
+	reactors, err := app.store.Reactions.GetReactors(r.Context(), post.ID, emoji, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, reactors); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// readReaction reads the emoji of a toggle request and checks it is one of
+// the configured reactions.
+func (app *application) readReaction(w http.ResponseWriter, r *http.Request) (string, error) {
+	var payload ReactionPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		return "", err
+	}
+
+	var Validate = validator.New()
+	if err := Validate.Struct(payload); err != nil {
+		return "", err
+	}
+
+	if !slices.Contains(app.config.reactions.emoji, payload.Emoji) {
+		return "", errors.New("unsupported reaction")
+	}
+
+	return payload.Emoji, nil
+}
+
+// attachPostReactions loads the reaction counts of a page of posts as seen by
+// the viewer.
+func (app *application) attachPostReactions(ctx context.Context, viewerID int64, posts []store.PostWithData) error {
+	ids := make([]int64, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+
+	reactions, err := app.store.Reactions.GetForPosts(ctx, viewerID, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].Reactions = reactions[posts[i].ID]
+	}
+
+	return nil
+}
+
+// attachCommentReactions loads the reaction counts of a list of comments and
+// of their loaded replies as seen by the viewer.
+func (app *application) attachCommentReactions(ctx context.Context, viewerID int64, comments []store.Comment) error {
+	reactions, err := app.store.Reactions.GetForComments(ctx, viewerID, collectCommentIDs(comments, nil))
+	if err != nil {
+		return err
+	}
+
+	setCommentReactions(comments, reactions)
+
+	return nil
+}
+
+func setCommentReactions(comments []store.Comment, reactions map[int64]store.Reactions) {
+	for i := range comments {
+		comments[i].Reactions = reactions[comments[i].ID]
+		setCommentReactions(comments[i].Replies, reactions)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"backendwithgo/internal/store"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+)
+
+// Repost godoc
+//
+//	@Summary		Reposts a post
+//	@Description	Boosts a post of a public account to the followers of the authenticated user
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		201		{object}	store.Repost
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [post]
+func (app *application) repostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getpostCtx(r)
+	user := app.getUserfromContext(r)
+
+	if post.UserID == user.ID {
+		app.badrequestresponse(w, r, errors.New("you cannot repost your own post"))
+		return
+	}
+
+	// bài của tài khoản private hoặc bài giới hạn người xem không cho lan ra ngoài
+	if post.User.IsPrivate {
+		app.badrequestresponse(w, r, errors.New("posts of private accounts cannot be reposted"))
+		return
+	}
+	if post.Visibility != store.PostVisibilityPublic {
+		app.badrequestresponse(w, r, errors.New("only public posts can be reposted"))
+		return
+	}
+
+	repost := &store.Repost{
+		UserID: user.ID,
+		PostID: post.ID,
+	}
+
+	if err := app.store.Reposts.Create(r.Context(), repost); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, errors.New("post already reposted"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, repost); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UndoRepost godoc
+//
+//	@Summary		Undoes a repost
+//	@Description	Removes the repost of a post by the authenticated user
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		204		{string}	string
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [delete]
+func (app *application) undoRepostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getpostCtx(r)
+	user := app.getUserfromContext(r)
+
+	if err := app.store.Reposts.Delete(r.Context(), user.ID, post.ID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadQuotedPost checks that the user may quote the post with the given ID:
+// it must exist and be visible to everyone who can see the quote.
+func (app *application) loadQuotedPost(ctx context.Context, user *store.User, postID int64) (*store.Post, error) {
+	quoted, err := app.store.Posts.GetByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	if quoted.UserID == user.ID {
+		return quoted, nil
+	}
+
+	if quoted.Status != store.PostStatusPublished || quoted.Visibility != store.PostVisibilityPublic || quoted.User.IsPrivate {
+		return nil, sql.ErrNoRows
+	}
+
+	return quoted, nil
+}
+
+// attachRepostCounts loads how often each post of a page was reposted and
+// quoted.
+func (app *application) attachRepostCounts(ctx context.Context, posts []store.PostWithData) error {
+	ids := make([]int64, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+
+	counts, err := app.store.Reposts.GetCounts(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		c := counts[posts[i].ID]
+		posts[i].RepostCount = c.Reposts
+		posts[i].QuoteCount = c.Quotes
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"backendwithgo/internal/diff"
+	"backendwithgo/internal/store"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type revisionDiff struct {
+	PostID      int64     `json:"post_id"`
+	From        int       `json:"from"`
+	To          int       `json:"to"`
+	Title       []diff.Op `json:"title"`
+	Content     []diff.Op `json:"content"`
+	TagsAdded   []string  `json:"tags_added"`
+	TagsRemoved []string  `json:"tags_removed"`
+}
+
+// GetPostRevisions godoc
+//
+//	@Summary		Lists the revisions of a post
+//	@Description	Lists the previous versions of a post, newest first
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	[]store.PostRevision
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions [get]
+func (app *application) getPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getpostCtx(r)
+
+	revisions, err := app.store.Revisions.GetByPostID(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, revisions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DiffPostRevisions godoc
+//
+//	@Summary		Compares two versions of a post
+//	@Description	Returns a line diff of the title and content and the tag changes between two versions of a post
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			from	query		int	true	"Old version"
+//	@Param			to		query		int	false	"New version, the current one by default"
+//	@Success		200		{object}	revisionDiff
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		413		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/diff [get]
+func (app *application) diffPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getpostCtx(r)
+
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		app.badrequestresponse(w, r, errors.New("from must be a version number"))
+		return
+	}
+
+	to := post.Version
+	if param := r.URL.Query().Get("to"); param != "" {
+		to, err = strconv.Atoi(param)
+		if err != nil {
+			app.badrequestresponse(w, r, errors.New("to must be a version number"))
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	versions := make([]*store.PostRevision, 0, 2)
+	for _, version := range []int{from, to} {
+		rev, err := app.store.Revisions.Get(ctx, post.ID, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				app.notfoundresponse(w, r, fmt.Errorf("version %d not found", version))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		versions = append(versions, rev)
+	}
+	old, cur := versions[0], versions[1]
+
+	title, err := diff.Lines(old.Title, cur.Title)
+	if err != nil {
+		app.payloadTooLargeResponse(w, r, err)
+		return
+	}
+	content, err := diff.Lines(old.Content, cur.Content)
+	if err != nil {
+		app.payloadTooLargeResponse(w, r, err)
+		return
+	}
+
+	res := revisionDiff{
+		PostID:      post.ID,
+		From:        from,
+		To:          to,
+		Title:       title,
+		Content:     content,
+		TagsAdded:   missingTags(cur.Tags, old.Tags),
+		TagsRemoved: missingTags(old.Tags, cur.Tags),
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, res); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RestorePostRevision godoc
+//
+//	@Summary		Restores a revision of a post
+//	@Description	Saves an old version of a post as its new current version. Only the owner or a moderator can restore.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			version	path		int	true	"Version to restore"
+//	@Success		200		{object}	store.Post
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/{version}/restore [post]
+func (app *application) restorePostRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	post := getpostCtx(r)
+	ctx := r.Context()
+
+	if version == post.Version {
+		app.badrequestresponse(w, r, errors.New("this is already the current version"))
+		return
+	}
+
+	rev, err := app.store.Revisions.Get(ctx, post.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	post.Title = rev.Title
+	post.Content = rev.Content
+
+	// bản cũ có thể có tag từ trước khi tag được chuẩn hoá, bỏ các tag không hợp lệ
+	post.Tags = []string{}
+	for _, tag := range rev.Tags {
+		name, err := store.NormalizeTag(tag)
+		if err == nil && !slices.Contains(post.Tags, name) && len(post.Tags) < store.MaxPostTags {
+			post.Tags = append(post.Tags, name)
+		}
+	}
+
+	if err := app.store.Posts.Update(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrEditConflict):
+			app.postEditConflict(w, r, post.ID)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.indexPost(ctx, post)
+
+	mentions, mentioned, err := app.store.Mentions.Sync(ctx, post.ID, nil, post.Content)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	post.Mentions = mentions
+	// người nhắc đến là tác giả, kể cả khi moderator sửa bài
+	if notifiesMentions(post) {
+		app.notifyMentions(ctx, post.UserID, post, mentioned)
+	}
+
+	w.Header().Set("ETag", postETag(post))
+	app.renderPost(post)
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// missingTags returns the tags of a that are not in b.
+func missingTags(a, b []string) []string {
+	tags := []string{}
+	for _, t := range a {
+		if !slices.Contains(b, t) {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
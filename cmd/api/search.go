@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backendwithgo/internal/search"
+	"backendwithgo/internal/store"
+)
+
+const (
+	// maxSearchCandidates bounds how many of the best matches are checked
+	// for visibility; results past it cannot be paged to.
+	maxSearchCandidates = 500
+	highlightSize       = 200
+)
+
+type SearchPostResult struct {
+	store.PostWithData
+	Score float64 `json:"score"`
+	// TitleHighlight and ContentHighlight are HTML-escaped excerpts with the
+	// matched words wrapped in <mark>.
+	TitleHighlight   string `json:"title_highlight"`
+	ContentHighlight string `json:"content_highlight"`
+}
+
+type SearchResponse struct {
+	Posts      []SearchPostResult       `json:"posts"`
+	PostsTotal int                      `json:"posts_total"`
+	Users      []store.UserSearchResult `json:"users"`
+	Tags       []store.Tag              `json:"tags"`
+}
+
+// Search godoc
+//
+//	@Summary		Searches posts, users and tags
+//	@Description	Full-text search of posts ranked by relevance, with highlighted excerpts, plus matching users and tags. type restricts the search to posts, users or tags. author_id, tag, since and until (RFC 3339 or YYYY-MM-DD, until inclusive) filter posts only; limit and offset page through posts.
+//	@Tags			search
+//	@Produce		json
+//	@Param			q			query		string	true	"Search query"
+//	@Param			type		query		string	false	"all (default), posts, users or tags"
+//	@Param			author_id	query		int		false	"Only posts of this user"
+//	@Param			tag			query		string	false	"Only posts with this tag"
+//	@Param			since		query		string	false	"Only posts published from this time"
+//	@Param			until		query		string	false	"Only posts published up to this time"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			offset		query		int		false	"Offset"
+//	@Success		200			{object}	SearchResponse
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/search [get]
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	text := strings.TrimSpace(qs.Get("q"))
+	if text == "" || len(text) > 200 {
+		app.badrequestresponse(w, r, errors.New("q must be between 1 and 200 characters"))
+		return
+	}
+
+	kind := qs.Get("type")
+	switch kind {
+	case "":
+		kind = "all"
+	case "all", "posts", "users", "tags":
+	default:
+		app.badrequestresponse(w, r, errors.New("type must be all, posts, users or tags"))
+		return
+	}
+
+	limit, err := intParam(qs.Get("limit"), 20, 1, 50)
+	if err != nil {
+		app.badrequestresponse(w, r, fmt.Errorf("limit %w", err))
+		return
+	}
+	offset, err := intParam(qs.Get("offset"), 0, 0, maxSearchCandidates)
+	if err != nil {
+		app.badrequestresponse(w, r, fmt.Errorf("offset %w", err))
+		return
+	}
+
+	query := store.SearchQuery{Text: text, Limit: maxSearchCandidates}
+
+	if v := qs.Get("author_id"); v != "" {
+		query.AuthorID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			app.badrequestresponse(w, r, errors.New("author_id must be a user ID"))
+			return
+		}
+	}
+	if v := qs.Get("tag"); v != "" {
+		query.Tag, err = store.NormalizeTag(v)
+		if err != nil {
+			app.badrequestresponse(w, r, err)
+			return
+		}
+	}
+	if query.Since, err = searchTime(qs.Get("since"), false); err != nil {
+		app.badrequestresponse(w, r, fmt.Errorf("since %w", err))
+		return
+	}
+	if query.Until, err = searchTime(qs.Get("until"), true); err != nil {
+		app.badrequestresponse(w, r, fmt.Errorf("until %w", err))
+		return
+	}
+
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	res := SearchResponse{
+		Posts: []SearchPostResult{},
+		Users: []store.UserSearchResult{},
+		Tags:  []store.Tag{},
+	}
+
+	if kind == "all" || kind == "posts" {
+		res.Posts, res.PostsTotal, err = app.searchPosts(ctx, user.ID, query, limit, offset)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	// trang "all" chỉ kèm vài user và tag đầu tiên
+	others := limit
+	if kind == "all" {
+		others = 5
+	}
+
+	if (kind == "all" || kind == "users") && len(text) <= 100 {
+		res.Users, err = app.store.Users.Search(ctx, user.ID, text, min(others, 20))
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if kind == "all" || kind == "tags" {
+		// q không phải tên tag hợp lệ thì không có tag nào khớp
+		if prefix, err := store.NormalizeTag(text); err == nil {
+			res.Tags, err = app.store.Tags.Autocomplete(ctx, prefix, min(others, 20))
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, res); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// searchPosts runs a post search and returns one page of the matches the
+// viewer may see, with the total number of them.
+func (app *application) searchPosts(ctx context.Context, viewerID int64, q store.SearchQuery, limit, offset int) ([]SearchPostResult, int, error) {
+	hits, err := app.search.Search(ctx, q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]int64, 0, len(hits))
+	scores := make(map[int64]float64, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.PostID)
+		scores[hit.PostID] = hit.Score
+	}
+
+	posts, err := app.store.Search.GetPosts(ctx, viewerID, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := len(posts)
+	posts = posts[min(offset, total):min(offset+limit, total)]
+
+	if err := app.enrichPosts(ctx, viewerID, posts); err != nil {
+		return nil, 0, err
+	}
+
+	terms := search.Terms(q.Text)
+	results := make([]SearchPostResult, 0, len(posts))
+	for _, post := range posts {
+		results = append(results, SearchPostResult{
+			PostWithData:     post,
+			Score:            scores[post.ID],
+			TitleHighlight:   search.Highlight(post.Title, terms, highlightSize),
+			ContentHighlight: search.Highlight(post.Content, terms, highlightSize),
+		})
+	}
+
+	return results, total, nil
+}
+
+// indexPost brings the search index up to date with a post: published posts
+// outside the trash are indexed, others removed. Errors are only logged, the
+// post itself is already saved.
+func (app *application) indexPost(ctx context.Context, post *store.Post) {
+	if post.Status != store.PostStatusPublished || post.DeletedAt != nil {
+		app.unindexPost(ctx, post.ID)
+		return
+	}
+
+	doc := store.SearchDocument{
+		PostID:   post.ID,
+		AuthorID: post.UserID,
+		Title:    post.Title,
+		Content:  post.Content,
+		Tags:     post.Tags,
+	}
+	if post.PublishedAt != nil {
+		doc.PublishedAt = *post.PublishedAt
+	}
+
+	if err := app.search.Index(ctx, doc); err != nil {
+		app.logger.Errorw("error indexing post", "post", post.ID, "error", err.Error())
+	}
+}
+
+func (app *application) unindexPost(ctx context.Context, postID int64) {
+	if err := app.search.Delete(ctx, postID); err != nil {
+		app.logger.Errorw("error removing post from search index", "post", postID, "error", err.Error())
+	}
+}
+
+// searchTime parses a since or until filter. A date without a time is the
+// start of that day, or the end of it for until.
+func searchTime(v string, until bool) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return nil, errors.New("must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	if until {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+func intParam(v string, def, lo, hi int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("must be between %d and %d", lo, hi)
+	}
+	return n, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"backendwithgo/internal/events"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const streamHeartbeat = 15 * time.Second
+
+// Stream godoc
+//
+//	@Summary		Streams real-time events
+//	@Description	Server-Sent Events stream of new feed posts, notifications and comment events for the authenticated user. Send Last-Event-ID to resume after a disconnect.
+//	@Tags			stream
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header		string	false	"ID of the last received event"
+//	@Success		200				{string}	string	"Event stream"
+//	@Failure		401				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream [get]
+func (app *application) streamHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserfromContext(r)
+
+	rc := http.NewResponseController(w)
+	// the server WriteTimeout would cut the stream after 30s
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	sub, err := app.events.Subscribe(events.UserTopic(user.ID), lastEventID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-app.streamsDone:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// publish pushes an event to the streams of the given users. Streaming is
+// best effort, failures are only logged.
+func (app *application) publish(ctx context.Context, userIDs []int64, eventType string, data any) {
+	for _, userID := range userIDs {
+		if err := app.events.Publish(ctx, events.UserTopic(userID), eventType, data); err != nil {
+			app.logger.Errorw("error publishing event", "type", eventType, "user", userID, "error", err.Error())
+		}
+	}
+}
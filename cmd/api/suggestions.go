@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetSuggestions godoc
+//
+//	@Summary		Lists people you may know
+//	@Description	Lists follow suggestions computed from friends-of-friends and shared tags. Suggestions are cached per user and recomputed in the background when stale, so the first request of a user may return none.
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Success		200		{object}	[]store.Suggestion
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/suggestions [get]
+func (app *application) getSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed < 1 || parsed > 20 {
+			app.badrequestresponse(w, r, errors.New("limit must be between 1 and 20"))
+			return
+		}
+		limit = parsed
+	}
+
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	stale, err := app.store.Suggestions.IsStale(ctx, user.ID, app.config.suggestions.ttl)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// tính lại ở job nền, request chỉ trả về cache hiện có
+	if stale {
+		if err := app.store.Suggestions.RequestRefresh(ctx, user.ID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	suggestions, err := app.store.Suggestions.Get(ctx, user.ID, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, suggestions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DismissSuggestion godoc
+//
+//	@Summary		Dismisses a suggestion
+//	@Description	Stops suggesting a user to the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"Suggested user ID"
+//	@Success		204		{string}	string	"Suggestion dismissed"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/suggestions/{userID} [delete]
+func (app *application) dismissSuggestionHandler(w http.ResponseWriter, r *http.Request) {
+	dismissedID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	if _, err := app.store.Users.GetByID(ctx, dismissedID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.Suggestions.Dismiss(ctx, user.ID, dismissedID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// refreshStaleSuggestions recomputes a batch of expired suggestion caches,
+// starting with the users whose suggestions were never computed, so that
+// requests are served from the cache.
+func (app *application) refreshStaleSuggestions(ctx context.Context) error {
+	userIDs, err := app.store.Suggestions.GetStaleUserIDs(ctx, app.config.suggestions.ttl, 100)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if err := app.store.Suggestions.Recompute(ctx, userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backendwithgo/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	minTrendingWindow = time.Hour
+	maxTrendingWindow = 7 * 24 * time.Hour
+)
+
+// GetTrendingTags godoc
+//
+//	@Summary		Lists trending tags
+//	@Description	Lists the tags used in the most public posts published within the window (a duration such as 6h, between 1h and 168h, default 24h). Tags used by more authors rank first.
+//	@Tags			tags
+//	@Produce		json
+//	@Param			window	query		string	false	"Time window"
+//	@Param			limit	query		int		false	"Limit"
+//	@Success		200		{object}	[]store.Tag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/trending [get]
+func (app *application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	window := 24 * time.Hour
+	if v := r.URL.Query().Get("window"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < minTrendingWindow || parsed > maxTrendingWindow {
+			app.badrequestresponse(w, r, errors.New("window must be a duration between 1h and 168h"))
+			return
+		}
+		window = parsed
+	}
+
+	limit, err := intParam(r.URL.Query().Get("limit"), 10, 1, 50)
+	if err != nil {
+		app.badrequestresponse(w, r, fmt.Errorf("limit %w", err))
+		return
+	}
+
+	tags, err := app.store.Tags.GetTrending(r.Context(), window, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// AutocompleteTags godoc
+//
+//	@Summary		Autocompletes tags
+//	@Description	Lists the tags starting with q that are used in public posts, most used first. q is normalized like post tags.
+//	@Tags			tags
+//	@Produce		json
+//	@Param			q		query		string	true	"Tag prefix"
+//	@Param			limit	query		int		false	"Limit"
+//	@Success		200		{object}	[]store.Tag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/autocomplete [get]
+func (app *application) autocompleteTagsHandler(w http.ResponseWriter, r *http.Request) {
+	prefix, err := store.NormalizeTag(r.URL.Query().Get("q"))
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	limit, err := intParam(r.URL.Query().Get("limit"), 10, 1, 20)
+	if err != nil {
+		app.badrequestresponse(w, r, fmt.Errorf("limit %w", err))
+		return
+	}
+
+	tags, err := app.store.Tags.Autocomplete(r.Context(), prefix, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetTagPosts godoc
+//
+//	@Summary		Lists the posts with a tag
+//	@Description	Lists the published posts with a tag that the authenticated user may see, newest first. Posts matched by the user's mutes are left out.
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag"
+//	@Param			limit	query		int		false	"Page size"
+//	@Param			cursor	query		string	false	"next_cursor of the previous page"
+//	@Success		200		{object}	store.TagPostsPage
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag, err := store.NormalizeTag(chi.URLParam(r, "tag"))
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	cq := store.CursorQuery{Limit: 20}
+	cq, err = cq.Parse(r)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	var Validate = validator.New()
+	if err := Validate.Struct(cq); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	page, err := app.store.Tags.GetPosts(ctx, user.ID, tag, cq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badrequestresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.enrichPosts(ctx, user.ID, page.Posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// FollowTag godoc
+//
+//	@Summary		Follows a tag
+//	@Description	Follows a tag so that posts with it show up in the feed. Following a tag again does nothing.
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag"
+//	@Success		204	{string}	string	"Tag followed"
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/follow [put]
+func (app *application) followTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, err := store.NormalizeTag(chi.URLParam(r, "tag"))
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+
+	if err := app.store.Tags.Follow(r.Context(), user.ID, tag); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UnfollowTag godoc
+//
+//	@Summary		Unfollows a tag
+//	@Description	Stops following a tag
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag"
+//	@Success		204	{string}	string	"Tag unfollowed"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/unfollow [put]
+func (app *application) unfollowTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, err := store.NormalizeTag(chi.URLParam(r, "tag"))
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+
+	if err := app.store.Tags.Unfollow(r.Context(), user.ID, tag); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, errors.New("you are not following this tag"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetFollowedTags godoc
+//
+//	@Summary		Lists the followed tags
+//	@Description	Lists the tags the authenticated user follows, by name
+//	@Tags			tags
+//	@Produce		json
+//	@Success		200	{object}	[]store.Tag
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tags [get]
+func (app *application) getFollowedTagsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserfromContext(r)
+
+	tags, err := app.store.Tags.GetFollowed(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// normalizePostTags normalizes the tags given for a post and checks there
+// are not too many of them.
+func normalizePostTags(tags []string) ([]string, error) {
+	normalized, err := store.NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(normalized) > store.MaxPostTags {
+		return nil, fmt.Errorf("a post can have at most %d tags", store.MaxPostTags)
+	}
+	return normalized, nil
+}
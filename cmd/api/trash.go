@@ -0,0 +1,152 @@
+package main
+
+import (
+	"backendwithgo/internal/store"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+// GetTrash godoc
+//
+//	@Summary		Lists deleted posts
+//	@Description	Lists the posts in the trash of the authenticated user, most recently deleted first. Admins see the trash of every user.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.Post
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/trash [get]
+func (app *application) getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	var validate = validator.New()
+	fq := store.PaginationQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(fq); err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	user := app.getUserfromContext(r)
+	ctx := r.Context()
+
+	isAdmin, err := app.checkRolePrecedence(ctx, user, "admin")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	posts, err := app.store.Posts.GetTrash(ctx, user.ID, isAdmin, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range posts {
+		app.renderPost(&posts[i])
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RestorePost godoc
+//
+//	@Summary		Restores a deleted post
+//	@Description	Takes a post out of the trash. Only its author or an admin may restore it.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	store.Post
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/trash/{postID}/restore [post]
+func (app *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		app.badrequestresponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	post, err := app.store.Posts.GetDeletedByID(ctx, postID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user := app.getUserfromContext(r)
+	if post.UserID != user.ID {
+		allowed, err := app.checkRolePrecedence(ctx, user, "admin")
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	if err := app.store.Posts.Restore(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.indexPost(ctx, post)
+
+	app.renderPost(post)
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// purgeTrash permanently deletes the posts kept in the trash for longer than
+// the retention period.
+func (app *application) purgeTrash(ctx context.Context) error {
+	for {
+		purged, err := app.store.Posts.PurgeDeleted(ctx, app.config.posts.trashRetention, 100)
+		if err != nil {
+			return err
+		}
+
+		if purged > 0 {
+			app.logger.Infow("purged deleted posts", "count", purged)
+		}
+
+		if purged < 100 {
+			return nil
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -39,6 +40,49 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 
 
 
+// SearchUsers godoc
+//
+//	@Summary		Searches users
+//	@Description	Prefix and fuzzy search on username and display name, ranked by exact match, mutual follows and follower count. Inactive, suspended and blocked users are left out.
+//	@Tags			users
+//	@Produce		json
+//	@Param			q		query		string	true	"Search query"
+//	@Param			limit	query		int		false	"Limit"
+//	@Success		200		{object}	[]store.UserSearchResult
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/search [get]
+func (app *application) searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" || len(q) > 100 {
+		app.badrequestresponse(w, r, errors.New("q must be between 1 and 100 characters"))
+		return
+	}
+
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed < 1 || parsed > 20 {
+			app.badrequestresponse(w, r, errors.New("limit must be between 1 and 20"))
+			return
+		}
+		limit = parsed
+	}
+
+	user := app.getUserfromContext(r)
+
+	results, err := app.store.Users.Search(r.Context(), user.ID, q, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 func (app *application) getUserfromContext(r *http.Request) *store.User {
 	user, _ := r.Context().Value(userCtxKey).(*store.User)
 	return user
@@ -51,14 +95,17 @@ type FollowUser struct {
 // FollowUser godoc
 //
 //	@Summary		Follows a user
-//	@Description	Follows a user by ID
+//	@Description	Follows a user by ID. Following a private account creates a pending follow request instead.
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path		int		true	"User ID"
+//	@Success		202		{string}	string	"Follow request sent"
 //	@Success		204		{string}	string	"User followed"
 //	@Failure		400		{object}	error	"User payload missing"
+//	@Failure		403		{object}	error	"User blocked"
 //	@Failure		404		{object}	error	"User not found"
+//	@Failure		409		{object}	error	"Already following or follow request already pending"
 //	@Security		ApiKeyAuth
 //	@Router			/users/{userID}/follow [put]
 func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -70,12 +117,78 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if followedID == followerUser.ID {
+		app.badrequestresponse(w, r, errors.New("you cannot follow yourself"))
+		return
+	}
+
 	ctx := r.Context()
 
-	if err := app.store.Followers.Follow(ctx, followerUser.ID, followedID); err != nil {
+	followed, err := app.store.Users.GetByID(ctx, followedID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notfoundresponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, followerUser.ID, followedID)
+	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+	if blocked {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if followed.IsPrivate {
+		if err := app.store.Followers.RequestFollow(ctx, followerUser.ID, followedID); err != nil {
+			switch {
+			case errors.Is(err, store.ErrConflict):
+				app.conflictResponse(w, r, errors.New("follow request already pending"))
+			case errors.Is(err, store.ErrAlreadyFollowing):
+				app.conflictResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		app.notify(ctx, &store.Notification{
+			UserID:     followedID,
+			Type:       store.NotificationFollowRequest,
+			TargetType: store.TargetUser,
+			TargetID:   followedID,
+			ActorID:    followerUser.ID,
+		})
+
+		if err := app.jsonResponse(w, http.StatusAccepted, map[string]string{"status": "pending"}); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.Followers.Follow(ctx, followerUser.ID, followedID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrAlreadyFollowing):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.notify(ctx, &store.Notification{
+		UserID:     followedID,
+		Type:       store.NotificationFollow,
+		TargetType: store.TargetUser,
+		TargetID:   followedID,
+		ActorID:    followerUser.ID,
+	})
 
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS user_mutes (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    target_user_id BIGINT NOT NULL DEFAULT 0,
+    value VARCHAR(100) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_user_mutes (user_id, kind, target_user_id, value),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS follow_requests;
+
+ALTER TABLE users DROP COLUMN is_private;
@@ -0,0 +1,5 @@
+DROP INDEX idx_users_display_name ON users;
+
+ALTER TABLE users
+DROP COLUMN suspended_at,
+DROP COLUMN display_name;
@@ -0,0 +1,5 @@
+ALTER TABLE users
+ADD COLUMN display_name VARCHAR(255) NOT NULL DEFAULT '',
+ADD COLUMN suspended_at TIMESTAMP NULL;
+
+CREATE INDEX idx_users_display_name ON users (display_name);
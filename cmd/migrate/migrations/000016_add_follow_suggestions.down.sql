@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS follow_suggestion_dismissals;
+DROP TABLE IF EXISTS follow_suggestion_runs;
+DROP TABLE IF EXISTS follow_suggestions;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS follow_suggestions (
+    user_id BIGINT NOT NULL,
+    suggested_id BIGINT NOT NULL,
+    score INT NOT NULL DEFAULT 0,
+    mutual_count INT NOT NULL DEFAULT 0,
+    shared_tags INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, suggested_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (suggested_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS follow_suggestion_runs (
+    user_id BIGINT PRIMARY KEY,
+    computed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS follow_suggestion_dismissals (
+    user_id BIGINT NOT NULL,
+    dismissed_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, dismissed_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (dismissed_id) REFERENCES users(id) ON DELETE CASCADE
+);
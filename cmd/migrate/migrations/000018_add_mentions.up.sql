@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS mentions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    post_id BIGINT NOT NULL,
+    comment_id BIGINT NULL,
+    user_id BIGINT NOT NULL,
+    start_offset INT NOT NULL,
+    length INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_mentions_source (post_id, comment_id),
+    INDEX idx_mentions_user (user_id, post_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
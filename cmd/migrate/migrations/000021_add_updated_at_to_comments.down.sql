@@ -0,0 +1,3 @@
+DROP INDEX idx_comments_post_id ON comments;
+
+ALTER TABLE comments DROP COLUMN updated_at;
@@ -0,0 +1,4 @@
+ALTER TABLE comments
+ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_comments_post_id ON comments (post_id, created_at);
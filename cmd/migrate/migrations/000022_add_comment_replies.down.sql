@@ -0,0 +1,8 @@
+ALTER TABLE comments DROP FOREIGN KEY fk_comments_parent;
+
+DROP INDEX idx_comments_parent_id ON comments;
+
+ALTER TABLE comments
+DROP COLUMN is_deleted,
+DROP COLUMN depth,
+DROP COLUMN parent_id;
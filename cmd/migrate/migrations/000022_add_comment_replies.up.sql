@@ -0,0 +1,7 @@
+ALTER TABLE comments
+ADD COLUMN parent_id BIGINT NULL,
+ADD COLUMN depth INT NOT NULL DEFAULT 0,
+ADD COLUMN is_deleted BOOLEAN NOT NULL DEFAULT FALSE,
+ADD CONSTRAINT fk_comments_parent FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_comments_parent_id ON comments (parent_id, id);
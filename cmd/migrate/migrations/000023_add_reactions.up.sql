@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS reactions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    post_id BIGINT NOT NULL,
+    comment_id BIGINT NULL,
+    comment_key BIGINT AS (IFNULL(comment_id, 0)) STORED,
+    user_id BIGINT NOT NULL,
+    emoji VARCHAR(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_reactions_user (user_id, post_id, comment_key, emoji),
+    INDEX idx_reactions_target (post_id, comment_key, emoji, created_at),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
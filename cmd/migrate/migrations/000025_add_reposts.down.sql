@@ -0,0 +1,5 @@
+ALTER TABLE posts DROP FOREIGN KEY fk_posts_quoted_post;
+
+ALTER TABLE posts DROP COLUMN quoted_post_id;
+
+DROP TABLE IF EXISTS reposts;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS reposts (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    post_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_reposts_user_post (user_id, post_id),
+    INDEX idx_reposts_post (post_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+ALTER TABLE posts
+ADD COLUMN quoted_post_id BIGINT NULL,
+ADD CONSTRAINT fk_posts_quoted_post FOREIGN KEY (quoted_post_id) REFERENCES posts(id) ON DELETE SET NULL;
@@ -0,0 +1,6 @@
+DROP INDEX idx_posts_status_publish_at ON posts;
+
+ALTER TABLE posts
+DROP COLUMN published_at,
+DROP COLUMN publish_at,
+DROP COLUMN status;
@@ -0,0 +1,8 @@
+ALTER TABLE posts
+ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'published',
+ADD COLUMN publish_at TIMESTAMP NULL,
+ADD COLUMN published_at TIMESTAMP NULL;
+
+UPDATE posts SET published_at = created_at;
+
+CREATE INDEX idx_posts_status_publish_at ON posts (status, publish_at);
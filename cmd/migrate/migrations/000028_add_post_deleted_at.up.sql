@@ -0,0 +1,4 @@
+ALTER TABLE posts
+ADD COLUMN deleted_at TIMESTAMP NULL;
+
+CREATE INDEX idx_posts_deleted_at ON posts (deleted_at);
@@ -0,0 +1,2 @@
+ALTER TABLE posts
+ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public';
@@ -0,0 +1,17 @@
+DROP INDEX idx_posts_published_at ON posts;
+
+ALTER TABLE posts
+ADD tags JSON NOT NULL DEFAULT (JSON_ARRAY());
+
+UPDATE posts p
+JOIN (
+    SELECT pt.post_id, CAST(CONCAT('[', GROUP_CONCAT(JSON_QUOTE(t.name) ORDER BY pt.position), ']') AS JSON) AS tags
+    FROM post_tags pt
+    JOIN tags t ON t.id = pt.tag_id
+    GROUP BY pt.post_id
+) pt ON pt.post_id = p.id
+SET p.tags = pt.tags;
+
+DROP TABLE IF EXISTS legacy_post_tags;
+DROP TABLE IF EXISTS post_tags;
+DROP TABLE IF EXISTS tags;
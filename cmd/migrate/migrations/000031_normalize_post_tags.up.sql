@@ -0,0 +1,77 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(30) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_tags_name (name)
+);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id BIGINT NOT NULL,
+    tag_id BIGINT NOT NULL,
+    position INT NOT NULL,
+    PRIMARY KEY (post_id, tag_id),
+    INDEX idx_post_tags_tag (tag_id, post_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+-- legacy_post_tags giữ lại nguyên bản tags JSON cũ, mỗi tag một dòng, kèm
+-- tên đã chuẩn hoá và kept = FALSE cho những tag không được chuyển sang
+-- (rỗng sau khi chuẩn hoá, trùng, hoặc sau tag thứ 8 của bài)
+CREATE TABLE IF NOT EXISTS legacy_post_tags (
+    post_id BIGINT NOT NULL,
+    pos INT NOT NULL,
+    tag TEXT CHARACTER SET utf8mb4 COLLATE utf8mb4_bin,
+    name VARCHAR(30) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin,
+    kept BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (post_id, pos),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+-- chuẩn hoá như store.NormalizeTag: bỏ khoảng trắng và '#', chữ thường;
+-- ký tự không phải chữ, số hay '_' được đổi thành '_' thay vì bỏ tag,
+-- rồi cắt còn 30 ký tự
+INSERT INTO legacy_post_tags (post_id, pos, tag, name)
+SELECT p.id, jt.pos, jt.tag,
+    NULLIF(LEFT(REGEXP_REPLACE(LOWER(TRIM(LEADING '#' FROM TRIM(jt.tag))), '[^\\p{L}\\p{N}_]+', '_'), 30), '')
+FROM posts p,
+    JSON_TABLE(p.tags, '$[*]' COLUMNS (
+        pos FOR ORDINALITY,
+        tag TEXT CHARACTER SET utf8mb4 COLLATE utf8mb4_bin PATH '$'
+    )) jt;
+
+INSERT IGNORE INTO tags (name)
+SELECT DISTINCT name FROM legacy_post_tags WHERE name IS NOT NULL;
+
+INSERT INTO post_tags (post_id, tag_id, position)
+SELECT post_id, tag_id, position - 1
+FROM (
+    SELECT l.post_id, t.id AS tag_id,
+        ROW_NUMBER() OVER (PARTITION BY l.post_id ORDER BY MIN(l.pos)) AS position
+    FROM legacy_post_tags l
+    JOIN tags t ON t.name = l.name
+    GROUP BY l.post_id, t.id
+) ranked
+WHERE position <= 8;
+
+-- đánh dấu lần xuất hiện đầu tiên của mỗi tag đã chuyển; các dòng còn
+-- kept = FALSE là những gì bị bỏ, xem bằng
+-- SELECT * FROM legacy_post_tags WHERE NOT kept
+UPDATE legacy_post_tags l
+JOIN (
+    SELECT l.post_id, MIN(l.pos) AS pos
+    FROM legacy_post_tags l
+    JOIN tags t ON t.name = l.name
+    JOIN post_tags pt ON pt.post_id = l.post_id AND pt.tag_id = t.id
+    GROUP BY l.post_id, t.id
+) k ON k.post_id = l.post_id AND k.pos = l.pos
+SET l.kept = TRUE;
+
+UPDATE IGNORE user_mutes
+SET value = LEFT(REGEXP_REPLACE(LOWER(TRIM(LEADING '#' FROM TRIM(value))), '[^\\p{L}\\p{N}_]+', '_'), 30)
+WHERE kind = 'tag';
+
+ALTER TABLE posts
+DROP COLUMN tags;
+
+CREATE INDEX idx_posts_published_at ON posts (published_at);
@@ -0,0 +1 @@
+DROP INDEX ft_posts_title_content ON posts;
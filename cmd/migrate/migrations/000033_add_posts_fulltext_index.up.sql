@@ -0,0 +1 @@
+CREATE FULLTEXT INDEX ft_posts_title_content ON posts (title, content);
@@ -0,0 +1,7 @@
+DROP INDEX idx_data_exports_status ON data_exports;
+
+DROP INDEX uq_data_exports_pending ON data_exports;
+
+ALTER TABLE data_exports
+DROP COLUMN pending_user_id,
+DROP COLUMN started_at;
@@ -0,0 +1,13 @@
+ALTER TABLE data_exports
+ADD started_at TIMESTAMP NULL,
+ADD pending_user_id BIGINT GENERATED ALWAYS AS (IF(status = 'pending', user_id, NULL)) STORED;
+
+-- các export đang chờ từ trước khi có job sẽ không bao giờ được xử lý
+UPDATE data_exports
+SET status = 'failed', error = 'export was interrupted', completed_at = NOW()
+WHERE status = 'pending';
+
+-- mỗi user chỉ có một export đang chờ
+CREATE UNIQUE INDEX uq_data_exports_pending ON data_exports (pending_user_id);
+
+CREATE INDEX idx_data_exports_status ON data_exports (status, started_at);
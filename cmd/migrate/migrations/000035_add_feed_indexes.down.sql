@@ -0,0 +1,3 @@
+DROP INDEX idx_reposts_user_created ON reposts;
+
+DROP INDEX idx_posts_user_published ON posts;
@@ -0,0 +1,4 @@
+-- mỗi nhánh của feed đọc ngược theo thời gian từ vị trí cursor
+CREATE INDEX idx_posts_user_published ON posts (user_id, published_at, id);
+
+CREATE INDEX idx_reposts_user_created ON reposts (user_id, created_at);
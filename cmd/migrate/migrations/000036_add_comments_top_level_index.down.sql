@@ -0,0 +1 @@
+DROP INDEX idx_comments_post_top ON comments;
@@ -0,0 +1,2 @@
+-- keyset của comment gốc (parent_id IS NULL) theo thời gian tạo
+CREATE INDEX idx_comments_post_top ON comments (post_id, parent_id, created_at, id);
@@ -0,0 +1,5 @@
+DROP INDEX idx_notifications_email ON notifications;
+
+ALTER TABLE notifications
+DROP COLUMN email_sent_at,
+DROP COLUMN email_queued_at;
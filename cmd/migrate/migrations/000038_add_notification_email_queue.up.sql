@@ -0,0 +1,7 @@
+-- email_queued_at is set while a notification waits to be emailed and
+-- cleared when the email job takes it, at email_sent_at.
+ALTER TABLE notifications
+ADD email_queued_at TIMESTAMP NULL,
+ADD email_sent_at TIMESTAMP NULL;
+
+CREATE INDEX idx_notifications_email ON notifications (email_queued_at, user_id);
@@ -0,0 +1,24 @@
+// Package blob stores uploaded files by key.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs under keys such as "attachments/42/photo.jpg". Keys use
+// forward slashes whatever the backend.
+type Store interface {
+	// Put writes the content of r under key, replacing any previous blob.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open reads the blob under key. The returned reader also implements
+	// io.Seeker when the backend supports it. It returns ErrNotFound when
+	// there is no such blob.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob under key. Deleting a missing blob is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+}
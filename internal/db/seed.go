@@ -137,16 +137,21 @@ func generatePosts(num int, users []*store.User) []*store.Post {
 			UserID:  user.ID,
 			Title:   titles[rand.Intn(len(titles))],
 			Content: contents[rand.Intn(len(contents))],
-			Tags: []string{
-				tags[rand.Intn(len(tags))],
-				tags[rand.Intn(len(tags))],
-				tags[rand.Intn(len(tags))],
-			},
+			Tags:    randomTags(3),
 		}
 	}
 	return posts
 }
 
+// randomTags picks n distinct tags, since a post cannot have a tag twice.
+func randomTags(n int) []string {
+	picked := make([]string, 0, n)
+	for _, i := range rand.Perm(len(tags))[:n] {
+		picked = append(picked, tags[i])
+	}
+	return picked
+}
+
 func generateComments(num int, posts []*store.Post, users []*store.User) []*store.Comment {
 	cms := make([]*store.Comment, num)
 	for i := 0; i < num; i++ {
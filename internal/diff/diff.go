@@ -0,0 +1,188 @@
+// Package diff computes line based differences between two texts.
+package diff
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// MaxLines is the most lines each text may have. Diffing takes time
+// proportional to the product of both line counts.
+const MaxLines = 10000
+
+var ErrTooLarge = errors.New("texts are too long to compare")
+
+// Op is one line of a diff: kept, added in the new text or removed from the
+// old one.
+type Op struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Lines returns the operations that turn a into b, using the longest common
+// subsequence of their lines. It returns ErrTooLarge when either text has
+// more than MaxLines lines.
+func Lines(a, b string) ([]Op, error) {
+	la, lb := split(a), split(b)
+	if len(la) > MaxLines || len(lb) > MaxLines {
+		return nil, ErrTooLarge
+	}
+
+	d := &differ{a: la, b: lb, ops: make([]Op, 0, len(la)+len(lb))}
+	d.intern()
+	d.diff(0, len(la), 0, len(lb))
+	return d.ops, nil
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// differ finds the longest common subsequence with Hirschberg's algorithm,
+// which only keeps two rows of the LCS table and so needs memory linear in
+// the number of lines.
+type differ struct {
+	a, b   []string
+	ai, bi []int // số hiệu của từng dòng, hai dòng giống nhau có cùng số
+	ops    []Op
+}
+
+func (d *differ) intern() {
+	ids := make(map[string]int, len(d.a)+len(d.b))
+	id := func(line string) int {
+		n, ok := ids[line]
+		if !ok {
+			n = len(ids)
+			ids[line] = n
+		}
+		return n
+	}
+
+	d.ai = make([]int, len(d.a))
+	for i, line := range d.a {
+		d.ai[i] = id(line)
+	}
+	d.bi = make([]int, len(d.b))
+	for j, line := range d.b {
+		d.bi[j] = id(line)
+	}
+}
+
+// diff appends the operations turning a[a0:a1] into b[b0:b1].
+func (d *differ) diff(a0, a1, b0, b1 int) {
+	for a0 < a1 && b0 < b1 && d.ai[a0] == d.bi[b0] {
+		d.emit(OpEqual, d.a[a0])
+		a0++
+		b0++
+	}
+	var suffix int
+	for a0 < a1 && b0 < b1 && d.ai[a1-1] == d.bi[b1-1] {
+		a1--
+		b1--
+		suffix++
+	}
+
+	switch {
+	case a0 == a1:
+		for j := b0; j < b1; j++ {
+			d.emit(OpInsert, d.b[j])
+		}
+	case b0 == b1:
+		for i := a0; i < a1; i++ {
+			d.emit(OpDelete, d.a[i])
+		}
+	case a1-a0 == 1:
+		d.diffLine(a0, b0, b1)
+	default:
+		// chia a làm đôi, tìm điểm cắt b sao cho tổng LCS hai nửa lớn nhất
+		mid := (a0 + a1) / 2
+		fwd := d.forward(a0, mid, b0, b1)
+		bwd := d.backward(mid, a1, b0, b1)
+
+		best, cut := -1, b0
+		for k := 0; k <= b1-b0; k++ {
+			if n := fwd[k] + bwd[k]; n > best {
+				best, cut = n, b0+k
+			}
+		}
+
+		d.diff(a0, mid, b0, cut)
+		d.diff(mid, a1, cut, b1)
+	}
+
+	for i := a1; i < a1+suffix; i++ {
+		d.emit(OpEqual, d.a[i])
+	}
+}
+
+// diffLine appends the operations turning the single line a[i] into
+// b[b0:b1].
+func (d *differ) diffLine(i, b0, b1 int) {
+	for j := b0; j < b1; j++ {
+		if d.ai[i] != d.bi[j] {
+			continue
+		}
+		for k := b0; k < j; k++ {
+			d.emit(OpInsert, d.b[k])
+		}
+		d.emit(OpEqual, d.a[i])
+		for k := j + 1; k < b1; k++ {
+			d.emit(OpInsert, d.b[k])
+		}
+		return
+	}
+
+	d.emit(OpDelete, d.a[i])
+	for j := b0; j < b1; j++ {
+		d.emit(OpInsert, d.b[j])
+	}
+}
+
+// forward returns, for every k, the LCS length of a[a0:a1] and b[b0:b0+k].
+func (d *differ) forward(a0, a1, b0, b1 int) []int {
+	prev := make([]int, b1-b0+1)
+	cur := make([]int, b1-b0+1)
+	for i := a0; i < a1; i++ {
+		for j := b0; j < b1; j++ {
+			k := j - b0 + 1
+			if d.ai[i] == d.bi[j] {
+				cur[k] = prev[k-1] + 1
+			} else {
+				cur[k] = max(prev[k], cur[k-1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// backward returns, for every k, the LCS length of a[a0:a1] and b[b0+k:b1].
+func (d *differ) backward(a0, a1, b0, b1 int) []int {
+	prev := make([]int, b1-b0+1)
+	cur := make([]int, b1-b0+1)
+	for i := a1 - 1; i >= a0; i-- {
+		for j := b1 - 1; j >= b0; j-- {
+			k := j - b0
+			if d.ai[i] == d.bi[j] {
+				cur[k] = prev[k+1] + 1
+			} else {
+				cur[k] = max(prev[k], cur[k+1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+func (d *differ) emit(typ, text string) {
+	d.ops = append(d.ops, Op{Type: typ, Text: text})
+}
@@ -0,0 +1,46 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Event is a message pushed to connected clients. Data is kept as raw JSON
+// so that events can travel through an external broker unchanged.
+type Event struct {
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Subscription delivers the events of a topic until Close is called. Events
+// is closed when the subscriber falls too far behind; clients are expected
+// to reconnect with the ID of the last event they received.
+type Subscription struct {
+	Events <-chan Event
+	Close  func()
+}
+
+// Broker fans events out to subscribers. InMemoryHub serves a single API
+// instance; running several instances needs a shared implementation (e.g.
+// Redis pub/sub) behind the same interface.
+type Broker interface {
+	Publish(ctx context.Context, topic, eventType string, data any) error
+	// Subscribe starts a subscription. When lastEventID is set, buffered
+	// events published after it are replayed first.
+	Subscribe(topic, lastEventID string) (*Subscription, error)
+}
+
+const (
+	PostCreated    = "post.created"
+	CommentCreated = "comment.created"
+	CommentUpdated = "comment.updated"
+	CommentDeleted = "comment.deleted"
+	Notification   = "notification"
+)
+
+// UserTopic is the topic of the events addressed to a user.
+func UserTopic(userID int64) string {
+	return fmt.Sprintf("user:%d", userID)
+}
@@ -0,0 +1,187 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const subscriberBuffer = 64
+
+type topic struct {
+	subscribers map[chan Event]struct{}
+	history     []storedEvent
+	// idleSince is when the last subscriber left.
+	idleSince time.Time
+}
+
+type storedEvent struct {
+	event Event
+	at    time.Time
+}
+
+// InMemoryHub is an in-process Broker. For Last-Event-ID resume it keeps the
+// last bufferSize events of every topic, for at most historyTTL. Events are
+// only kept for topics that have a subscriber or had one within historyTTL:
+// publishing to a user that is not connected costs nothing. Event IDs are
+// prefixed with the start time of the hub, so IDs from a previous process
+// are not replayed.
+type InMemoryHub struct {
+	sync.Mutex
+	topics     map[string]*topic
+	bufferSize int
+	historyTTL time.Duration
+	boot       string
+	seq        uint64
+	lastSweep  time.Time
+}
+
+func NewInMemoryHub(bufferSize int, historyTTL time.Duration) *InMemoryHub {
+	return &InMemoryHub{
+		topics:     make(map[string]*topic),
+		bufferSize: bufferSize,
+		historyTTL: historyTTL,
+		boot:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		lastSweep:  time.Now(),
+	}
+}
+
+func (h *InMemoryHub) Publish(ctx context.Context, topicName, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	now := time.Now()
+	h.sweep(now)
+
+	t, ok := h.topics[topicName]
+	if !ok {
+		// không ai đang hay vừa mới nghe topic này, không cần lưu lại
+		return nil
+	}
+
+	h.seq++
+	event := Event{
+		ID:   fmt.Sprintf("%s-%d", h.boot, h.seq),
+		Type: eventType,
+		Data: payload,
+	}
+
+	t.history = append(t.history, storedEvent{event: event, at: now})
+	if len(t.history) > h.bufferSize {
+		t.history = t.history[len(t.history)-h.bufferSize:]
+	}
+
+	for ch := range t.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// subscriber quá chậm: đóng để client kết nối lại với Last-Event-ID
+			delete(t.subscribers, ch)
+			close(ch)
+			if len(t.subscribers) == 0 {
+				t.idleSince = now
+			}
+		}
+	}
+
+	return nil
+}
+
+func (h *InMemoryHub) Subscribe(topicName, lastEventID string) (*Subscription, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	now := time.Now()
+	h.sweep(now)
+
+	t := h.topic(topicName)
+	t.expire(now.Add(-h.historyTTL))
+	ch := make(chan Event, subscriberBuffer+h.bufferSize)
+
+	if seq, ok := h.parseID(lastEventID); ok {
+		for _, e := range t.history {
+			if s, _ := h.parseID(e.event.ID); s > seq {
+				ch <- e.event
+			}
+		}
+	}
+
+	t.subscribers[ch] = struct{}{}
+
+	var once sync.Once
+	closeFn := func() {
+		once.Do(func() {
+			h.Lock()
+			defer h.Unlock()
+
+			if _, ok := t.subscribers[ch]; ok {
+				delete(t.subscribers, ch)
+				close(ch)
+				if len(t.subscribers) == 0 {
+					t.idleSince = time.Now()
+				}
+			}
+		})
+	}
+
+	return &Subscription{Events: ch, Close: closeFn}, nil
+}
+
+func (h *InMemoryHub) topic(name string) *topic {
+	t, ok := h.topics[name]
+	if !ok {
+		t = &topic{subscribers: make(map[chan Event]struct{})}
+		h.topics[name] = t
+	}
+	return t
+}
+
+// sweep drops the events older than historyTTL and the topics nobody
+// listened to within historyTTL. It only walks the topics once per
+// historyTTL; Subscribe also trims the history of its topic before replaying it.
+func (h *InMemoryHub) sweep(now time.Time) {
+	cutoff := now.Add(-h.historyTTL)
+	if h.lastSweep.After(cutoff) {
+		return
+	}
+	h.lastSweep = now
+
+	for name, t := range h.topics {
+		t.expire(cutoff)
+		if len(t.subscribers) == 0 && t.idleSince.Before(cutoff) {
+			delete(h.topics, name)
+		}
+	}
+}
+
+// expire drops the events published before cutoff.
+func (t *topic) expire(cutoff time.Time) {
+	i := 0
+	for i < len(t.history) && t.history[i].at.Before(cutoff) {
+		i++
+	}
+	t.history = t.history[i:]
+}
+
+// parseID returns the sequence number of an event ID issued by this hub.
+func (h *InMemoryHub) parseID(id string) (uint64, bool) {
+	boot, seq, ok := strings.Cut(id, "-")
+	if !ok || boot != h.boot {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -6,6 +6,9 @@ const (
 	FromName            = "myapp"
 	maxRetires          = 3
 	UserWelcomeTemplate = "user_invitation.tmpl"
+	DataExportTemplate  = "user_export.tmpl"
+
+	NotificationDigestTemplate = "notification_digest.tmpl"
 )
 
 //go:embed "templates"
@@ -13,4 +16,10 @@ var FS embed.FS
 
 type Client interface {
 	Send(templateFile, username, email string, data any, isSandbox bool) (int, error)
+}
+
+// HeaderProvider is implemented by template data that needs extra email
+// headers, such as List-Unsubscribe.
+type HeaderProvider interface {
+	Headers() map[string]string
 }
\ No newline at end of file
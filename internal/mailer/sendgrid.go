@@ -4,7 +4,7 @@ package mailer
 import (
 	"bytes"
 	"fmt"
-	"text/template"
+	"html/template"
 	"time"
 
 	"github.com/sendgrid/sendgrid-go"
@@ -49,6 +49,11 @@ func (m *SendGridMailer) Send(templateFile, username, email string, data any, is
 	}
 
 	message := mail.NewSingleEmail(from, subject.String(), to, "", body.String())
+	if hp, ok := data.(HeaderProvider); ok {
+		for key, value := range hp.Headers() {
+			message.SetHeader(key, value)
+		}
+	}
 
 	message.SetMailSettings(&mail.MailSettings{
 		SandboxMode: &mail.Setting{
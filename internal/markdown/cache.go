@@ -0,0 +1,66 @@
+package markdown
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache keeps the HTML of recently rendered posts. The content of a post only
+// changes together with its version, so a (post ID, version) pair always
+// renders the same and entries never need to be invalidated. The least
+// recently used entries are dropped once the cache is full.
+type Cache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[cacheKey]*list.Element
+}
+
+type cacheKey struct {
+	postID  int64
+	version int
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	html string
+}
+
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:  size,
+		order: list.New(),
+		items: make(map[cacheKey]*list.Element),
+	}
+}
+
+// Render returns the HTML of a version of a post, rendering src only when it
+// is not cached yet.
+func (c *Cache) Render(postID int64, version int, src string) string {
+	key := cacheKey{postID: postID, version: version}
+
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		html := el.Value.(*cacheEntry).html
+		c.mu.Unlock()
+		return html
+	}
+	c.mu.Unlock()
+
+	html := Render(src)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[key]; !ok && c.size > 0 {
+		c.items[key] = c.order.PushFront(&cacheEntry{key: key, html: html})
+		for c.order.Len() > c.size {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*cacheEntry).key)
+		}
+	}
+
+	return html
+}
@@ -0,0 +1,711 @@
+// Package markdown renders the CommonMark subset accepted in posts to HTML.
+//
+// Supported blocks are paragraphs, ATX and setext headings, fenced code
+// blocks, block quotes, bullet and ordered lists and thematic breaks. Inline
+// there are code spans, emphasis, strong emphasis, links, autolinks, hard line
+// breaks and backslash escapes. Images are rendered as links to the image.
+//
+// The output is safe by construction: every piece of the source is escaped,
+// raw HTML is shown as text, and only the tags above are ever produced. Links
+// must be absolute http, https or mailto URLs and carry rel="nofollow"; links
+// to anything else are reduced to their text.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxNesting bounds how deep block quotes, lists and emphasis may nest so
+// that crafted input cannot make rendering recurse without limit.
+const maxNesting = 16
+
+// maxLinkText bounds the text of a link, like CommonMark bounds link labels,
+// so that unmatched brackets are not searched to the end of the text.
+const maxLinkText = 1000
+
+// maxLinkDestination bounds the destination and title of a link for the
+// same reason: without it every '[' of "[a](" repeated rescans the rest of
+// the text.
+const maxLinkDestination = 2000
+
+var (
+	atxHeading    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	thematicBreak = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceOpen     = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^`]*)$")
+	bulletItem    = regexp.MustCompile(`^ {0,3}([-*+])(?:[ \t]+|$)`)
+	orderedItem   = regexp.MustCompile(`^ {0,3}(\d{1,9})([.)])(?:[ \t]+|$)`)
+	quoteLine     = regexp.MustCompile(`^ {0,3}> ?`)
+	setextH1      = regexp.MustCompile(`^ {0,3}=+[ \t]*$`)
+	setextH2      = regexp.MustCompile(`^ {0,3}-+[ \t]*$`)
+	languageName  = regexp.MustCompile(`^[A-Za-z0-9_+#.-]+$`)
+)
+
+var allowedSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// Render converts Markdown source to sanitized HTML.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	src = strings.ReplaceAll(src, "\x00", "�")
+
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		lines[i] = expandLeadingTabs(line)
+	}
+
+	var b strings.Builder
+	renderBlocks(&b, lines, false, 0)
+	return b.String()
+}
+
+// renderBlocks renders a sequence of block-level lines. In a tight list item
+// paragraphs are written without <p> tags.
+func renderBlocks(b *strings.Builder, lines []string, tight bool, depth int) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case isBlank(line):
+			i++
+
+		case fenceOpen.MatchString(line):
+			i = renderFence(b, lines, i)
+
+		case atxHeading.MatchString(line):
+			m := atxHeading.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + renderInline(strings.TrimSpace(m[2]), depth, false) + "</h" + level + ">\n")
+			i++
+
+		case thematicBreak.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case quoteLine.MatchString(line):
+			i = renderQuote(b, lines, i, depth)
+
+		case isListItem(line):
+			i = renderList(b, lines, i, depth)
+
+		default:
+			i = renderParagraph(b, lines, i, tight, depth)
+		}
+	}
+}
+
+func renderFence(b *strings.Builder, lines []string, i int) int {
+	m := fenceOpen.FindStringSubmatch(lines[i])
+	marker := m[1]
+	info := strings.Fields(m[2])
+
+	b.WriteString("<pre><code")
+	if len(info) > 0 && languageName.MatchString(info[0]) {
+		b.WriteString(` class="language-` + html.EscapeString(info[0]) + `"`)
+	}
+	b.WriteString(">")
+
+	i++
+	for ; i < len(lines); i++ {
+		closing := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(closing, marker) && strings.Trim(closing, marker[:1]) == "" {
+			i++
+			break
+		}
+		b.WriteString(html.EscapeString(lines[i]) + "\n")
+	}
+
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+func renderQuote(b *strings.Builder, lines []string, i int, depth int) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if loc := quoteLine.FindStringIndex(line); loc != nil {
+			inner = append(inner, line[loc[1]:])
+			continue
+		}
+		// dòng tiếp nối của đoạn văn trong trích dẫn
+		if !isBlank(line) && !startsBlock(line) && len(inner) > 0 && !isBlank(inner[len(inner)-1]) {
+			inner = append(inner, line)
+			continue
+		}
+		break
+	}
+
+	b.WriteString("<blockquote>\n")
+	if depth < maxNesting {
+		renderBlocks(b, inner, false, depth+1)
+	} else {
+		renderParagraph(b, inner, 0, false, depth)
+	}
+	b.WriteString("</blockquote>\n")
+	return i
+}
+
+type listMarker struct {
+	ordered bool
+	// delim is the bullet character of bullet lists and the delimiter
+	// after the number of ordered lists; a new delimiter starts a new list.
+	delim string
+	start int
+	width int
+}
+
+func parseListMarker(line string) (listMarker, bool) {
+	if thematicBreak.MatchString(line) {
+		return listMarker{}, false
+	}
+	if m := bulletItem.FindStringSubmatchIndex(line); m != nil {
+		return listMarker{delim: line[m[2]:m[3]], width: markerWidth(line, m[3], m[1])}, true
+	}
+	if m := orderedItem.FindStringSubmatchIndex(line); m != nil {
+		start, _ := strconv.Atoi(line[m[2]:m[3]])
+		return listMarker{ordered: true, delim: line[m[4]:m[5]], start: start, width: markerWidth(line, m[5], m[1])}, true
+	}
+	return listMarker{}, false
+}
+
+// markerWidth is the indentation of the content of a list item. Content
+// indented by more than four spaces after the marker keeps the extra spaces.
+func markerWidth(line string, markerEnd, matchEnd int) int {
+	if matchEnd == len(line) || matchEnd-markerEnd > 4 {
+		return markerEnd + 1
+	}
+	return matchEnd
+}
+
+// content is the first line of the item without its marker.
+func (m listMarker) content(line string) string {
+	if m.width >= len(line) {
+		return ""
+	}
+	return line[m.width:]
+}
+
+func isListItem(line string) bool {
+	_, ok := parseListMarker(line)
+	return ok
+}
+
+func renderList(b *strings.Builder, lines []string, i int, depth int) int {
+	first, _ := parseListMarker(lines[i])
+
+	var items [][]string
+	var current []string
+	width := first.width
+	loose := false
+
+	for i < len(lines) {
+		line := lines[i]
+
+		if m, ok := parseListMarker(line); ok && m.ordered == first.ordered && m.delim == first.delim && indentOf(line) < width {
+			if current != nil {
+				items = append(items, current)
+			}
+			current = []string{m.content(line)}
+			width = m.width
+			i++
+			continue
+		}
+
+		if isBlank(line) {
+			j := i + 1
+			for j < len(lines) && isBlank(lines[j]) {
+				j++
+			}
+			if j == len(lines) {
+				i = j
+				break
+			}
+			if indentOf(lines[j]) >= width {
+				loose = true
+				for ; i < j; i++ {
+					current = append(current, "")
+				}
+				continue
+			}
+			if m, ok := parseListMarker(lines[j]); ok && m.ordered == first.ordered && m.delim == first.delim {
+				loose = true
+				i = j
+				continue
+			}
+			break
+		}
+
+		if indentOf(line) >= width {
+			current = append(current, sliceAt(line, width))
+			i++
+			continue
+		}
+
+		// dòng tiếp nối lười của đoạn văn trong mục
+		if !startsBlock(line) && len(current) > 0 && !isBlank(current[len(current)-1]) {
+			current = append(current, strings.TrimLeft(line, " "))
+			i++
+			continue
+		}
+
+		break
+	}
+	items = append(items, current)
+
+	tag := "ul"
+	if first.ordered {
+		tag = "ol"
+	}
+
+	b.WriteString("<" + tag)
+	if first.ordered && first.start != 1 {
+		b.WriteString(` start="` + strconv.Itoa(first.start) + `"`)
+	}
+	b.WriteString(">\n")
+
+	for _, item := range items {
+		var ib strings.Builder
+		if depth < maxNesting {
+			renderBlocks(&ib, item, !loose, depth+1)
+		} else {
+			renderParagraph(&ib, item, 0, !loose, depth)
+		}
+
+		content := strings.TrimSuffix(ib.String(), "\n")
+		if loose && content != "" {
+			content = "\n" + content + "\n"
+		}
+		b.WriteString("<li>" + content + "</li>\n")
+	}
+
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func renderParagraph(b *strings.Builder, lines []string, i int, tight bool, depth int) int {
+	var para []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) {
+			break
+		}
+
+		if len(para) > 0 {
+			if setextH1.MatchString(line) || setextH2.MatchString(line) {
+				level := "1"
+				if setextH2.MatchString(line) {
+					level = "2"
+				}
+				text := strings.TrimSpace(strings.Join(para, "\n"))
+				b.WriteString("<h" + level + ">" + renderInline(text, depth, false) + "</h" + level + ">\n")
+				return i + 1
+			}
+			if startsBlock(line) {
+				break
+			}
+		}
+
+		para = append(para, strings.TrimLeft(line, " "))
+	}
+
+	if len(para) == 0 {
+		return i
+	}
+
+	text := renderInline(strings.TrimRight(strings.Join(para, "\n"), " "), depth, false)
+	if tight {
+		b.WriteString(text + "\n")
+	} else {
+		b.WriteString("<p>" + text + "</p>\n")
+	}
+	return i
+}
+
+// startsBlock reports whether a line interrupts a paragraph.
+func startsBlock(line string) bool {
+	if fenceOpen.MatchString(line) || atxHeading.MatchString(line) ||
+		thematicBreak.MatchString(line) || quoteLine.MatchString(line) {
+		return true
+	}
+
+	m, ok := parseListMarker(line)
+	if !ok || strings.TrimSpace(m.content(line)) == "" {
+		return false
+	}
+	// chỉ danh sách có thứ tự bắt đầu từ 1 mới cắt ngang đoạn văn
+	return !m.ordered || m.start == 1
+}
+
+func renderInline(s string, depth int, inLink bool) string {
+	var b strings.Builder
+	// noCloser remembers the delimiter runs ('*' or '_' by length) that have
+	// no closing run left, so that a long text of unmatched openers is not
+	// searched again for each of them.
+	var noCloser [2][4]bool
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			b.WriteString("<br>\n")
+			i += 2
+
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+
+		case c == '`':
+			n := runLength(s, i)
+			if end := findCodeSpanEnd(s, i+n, n); end >= 0 {
+				b.WriteString("<code>" + html.EscapeString(codeSpanText(s[i+n:end])) + "</code>")
+				i = end + n
+			} else {
+				b.WriteString(s[i : i+n])
+				i += n
+			}
+
+		case c == '*' || c == '_':
+			n := runLength(s, i)
+			if out, next, ok := emphasis(s, i, n, depth, inLink, &noCloser); ok {
+				b.WriteString(out)
+				i = next
+			} else {
+				b.WriteString(s[i : i+n])
+				i += n
+			}
+
+		case !inLink && (c == '[' || (c == '!' && i+1 < len(s) && s[i+1] == '[')):
+			if out, next, ok := link(s, i, depth); ok {
+				b.WriteString(out)
+				i = next
+			} else {
+				b.WriteString(html.EscapeString(s[i : i+1]))
+				i++
+			}
+
+		case !inLink && c == '<':
+			if out, next, ok := autolink(s, i); ok {
+				b.WriteString(out)
+				i = next
+			} else {
+				b.WriteString("&lt;")
+				i++
+			}
+
+		case c == ' ':
+			n := 0
+			for i+n < len(s) && s[i+n] == ' ' {
+				n++
+			}
+			if i+n < len(s) && s[i+n] == '\n' {
+				if n >= 2 {
+					b.WriteString("<br>")
+				}
+				b.WriteString("\n")
+				i += n + 1
+				for i < len(s) && s[i] == ' ' {
+					i++
+				}
+			} else {
+				b.WriteString(s[i : i+n])
+				i += n
+			}
+
+		default:
+			b.WriteString(html.EscapeString(s[i : i+1]))
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+// emphasis renders a run of n '*' or '_' at i and its matching closing run.
+func emphasis(s string, i, n, depth int, inLink bool, noCloser *[2][4]bool) (string, int, bool) {
+	c := s[i]
+	if n > 3 || depth >= maxNesting || i+n >= len(s) || isSpace(s[i+n]) {
+		return "", 0, false
+	}
+	if c == '_' && i > 0 && isAlnum(s[i-1]) {
+		return "", 0, false
+	}
+
+	kind := 0
+	if c == '_' {
+		kind = 1
+	}
+	if noCloser[kind][n] {
+		return "", 0, false
+	}
+
+	for j := i + n; j < len(s); {
+		switch s[j] {
+		case '\\':
+			j += 2
+		case '`':
+			m := runLength(s, j)
+			if end := findCodeSpanEnd(s, j+m, m); end >= 0 {
+				j = end + m
+			} else {
+				j += m
+			}
+		case c:
+			m := runLength(s, j)
+			if m == n && !isSpace(s[j-1]) && !(c == '_' && j+m < len(s) && isAlnum(s[j+m])) {
+				inner := renderInline(s[i+n:j], depth+1, inLink)
+				switch n {
+				case 1:
+					inner = "<em>" + inner + "</em>"
+				case 2:
+					inner = "<strong>" + inner + "</strong>"
+				default:
+					inner = "<em><strong>" + inner + "</strong></em>"
+				}
+				return inner, j + m, true
+			}
+			j += m
+		default:
+			j++
+		}
+	}
+
+	noCloser[kind][n] = true
+	return "", 0, false
+}
+
+// link renders [text](destination "title") or, for images, ![alt](source)
+// as a link to the image.
+func link(s string, i, depth int) (string, int, bool) {
+	image := s[i] == '!'
+	open := i
+	if image {
+		open++
+	}
+
+	// tìm ']' khớp với '['
+	close, level := -1, 0
+	for j := open; j < len(s) && j <= open+maxLinkText && close < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			level++
+		case ']':
+			level--
+			if level == 0 {
+				close = j
+			}
+		}
+	}
+	if close < 0 || close+1 >= len(s) || s[close+1] != '(' {
+		return "", 0, false
+	}
+
+	j := skipSpaces(s, close+2)
+	limit := min(len(s), j+maxLinkDestination)
+	var dest string
+	if j < len(s) && s[j] == '<' {
+		end := strings.IndexAny(s[j+1:limit], ">\n")
+		if end < 0 || s[j+1+end] != '>' {
+			return "", 0, false
+		}
+		dest = s[j+1 : j+1+end]
+		j += end + 2
+	} else {
+		start, parens := j, 0
+		for ; j < limit; j++ {
+			if s[j] == '\\' && j+1 < len(s) {
+				j++
+				continue
+			}
+			if s[j] == '(' {
+				parens++
+			} else if s[j] == ')' {
+				if parens == 0 {
+					break
+				}
+				parens--
+			} else if isSpace(s[j]) {
+				break
+			}
+		}
+		if j == limit && limit < len(s) {
+			return "", 0, false
+		}
+		dest = s[start:j]
+	}
+
+	title := ""
+	if k := skipSpaces(s, j); k > j && k < len(s) && (s[k] == '"' || s[k] == '\'') {
+		end := strings.IndexByte(s[k+1:min(len(s), k+1+maxLinkDestination)], s[k])
+		if end < 0 {
+			return "", 0, false
+		}
+		title = s[k+1 : k+1+end]
+		j = k + end + 2
+	}
+
+	j = skipSpaces(s, j)
+	if j >= len(s) || s[j] != ')' {
+		return "", 0, false
+	}
+
+	text := s[open+1 : close]
+	var label string
+	if image {
+		label = html.EscapeString(unescape(text))
+	} else {
+		label = renderInline(text, depth+1, true)
+	}
+
+	href, ok := safeURL(unescape(dest))
+	if !ok {
+		return label, j + 1, true
+	}
+
+	out := `<a href="` + html.EscapeString(href) + `"`
+	if title != "" {
+		out += ` title="` + html.EscapeString(unescape(title)) + `"`
+	}
+	out += ` rel="nofollow">` + label + `</a>`
+	return out, j + 1, true
+}
+
+// autolink renders <scheme:address>.
+func autolink(s string, i int) (string, int, bool) {
+	end := strings.IndexAny(s[i+1:], "<> \t\n")
+	if end < 0 || s[i+1+end] != '>' {
+		return "", 0, false
+	}
+
+	raw := s[i+1 : i+1+end]
+	if !strings.Contains(raw, ":") {
+		if !strings.Contains(raw, "@") {
+			return "", 0, false
+		}
+		raw = "mailto:" + raw
+	}
+
+	href, ok := safeURL(raw)
+	if !ok {
+		return "", 0, false
+	}
+
+	text := strings.TrimPrefix(s[i+1:i+1+end], "mailto:")
+	return `<a href="` + html.EscapeString(href) + `" rel="nofollow">` + html.EscapeString(text) + `</a>`, i + end + 2, true
+}
+
+// safeURL returns the normalized URL when it is an absolute http, https or
+// mailto URL.
+func safeURL(raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || !allowedSchemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	if u.Scheme != "mailto" && u.Host == "" {
+		return "", false
+	}
+	return u.String(), true
+}
+
+func findCodeSpanEnd(s string, from, n int) int {
+	for j := from; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+		m := runLength(s, j)
+		if m == n {
+			return j
+		}
+		j += m
+	}
+	return -1
+}
+
+func codeSpanText(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) >= 2 && s[0] == ' ' && s[len(s)-1] == ' ' && strings.Trim(s, " ") != "" {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func runLength(s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	return i
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// sliceAt drops the first n columns of a line, or all of its indentation
+// when it is indented less.
+func sliceAt(line string, n int) string {
+	return line[min(n, indentOf(line)):]
+}
+
+func expandLeadingTabs(line string) string {
+	i := 0
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	if !strings.Contains(line[:i], "\t") {
+		return line
+	}
+
+	col := 0
+	for _, c := range line[:i] {
+		if c == '\t' {
+			col += 4 - col%4
+		} else {
+			col++
+		}
+	}
+	return strings.Repeat(" ", col) + line[i:]
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
@@ -0,0 +1,123 @@
+package markdown
+
+import (
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"paragraph", "hello", "<p>hello</p>\n"},
+		{"emphasis", "**bold** and *em* and ***both***", "<p><strong>bold</strong> and <em>em</em> and <em><strong>both</strong></em></p>\n"},
+		{"headings", "# h1\n\n## h2", "<h1>h1</h1>\n<h2>h2</h2>\n"},
+		{"list", "- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{"nested quote", "> > quote", "<blockquote>\n<blockquote>\n<p>quote</p>\n</blockquote>\n</blockquote>\n"},
+		{"code span", "`<b>`", "<p><code>&lt;b&gt;</code></p>\n"},
+		{"fenced code", "```\n<script>\n```", "<pre><code>&lt;script&gt;\n</code></pre>\n"},
+		{"fence language", "```go\nx\n```", "<pre><code class=\"language-go\">x\n</code></pre>\n"},
+		{"fence bad language", "```\"><script>\nx\n```", "<pre><code>x\n</code></pre>\n"},
+		{"entity is text", "&lt;", "<p>&amp;lt;</p>\n"},
+		{"nested brackets", "[a [b] c](https://x.y)", `<p><a href="https://x.y" rel="nofollow">a [b] c</a></p>` + "\n"},
+		{"autolink", "<https://a.com>", `<p><a href="https://a.com" rel="nofollow">https://a.com</a></p>` + "\n"},
+		{"email autolink", "<a@b.com>", `<p><a href="mailto:a@b.com" rel="nofollow">a@b.com</a></p>` + "\n"},
+		{"mailto link", "[x](mailto:a@b.com)", `<p><a href="mailto:a@b.com" rel="nofollow">x</a></p>` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.in); got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderXSS(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"script tag", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"img onerror", "<img src=x onerror=alert(1)>", "<p>&lt;img src=x onerror=alert(1)&gt;</p>\n"},
+		{"javascript link", "[x](javascript:alert(1))", "<p>x</p>\n"},
+		{"mixed case scheme", "[x](JaVaScRiPt:alert(1))", "<p>x</p>\n"},
+		{"vbscript link", "[x](vbscript:msgbox)", "<p>x</p>\n"},
+		{"data link", "[x](data:text/html;base64,PHNjcmlwdD4=)", "<p>x</p>\n"},
+		{"relative link", "[x](//evil.com)", "<p>x</p>\n"},
+		{"javascript autolink", "<javascript:alert(1)>", "<p>&lt;javascript:alert(1)&gt;</p>\n"},
+		{"quote in destination", `[x](http://a.com/"onmouseover="alert(1))`, `<p><a href="http://a.com/%22onmouseover=%22alert%281%29" rel="nofollow">x</a></p>` + "\n"},
+		{"quote in image alt", `![img"onerror=alert(1)](https://a.com/i.png)`, `<p><a href="https://a.com/i.png" rel="nofollow">img&#34;onerror=alert(1)</a></p>` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.in); got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// allowedTag matches the tags Render may produce, with the only attributes
+// it may set.
+var (
+	anyTag     = regexp.MustCompile(`<[^>]*>`)
+	allowedTag = regexp.MustCompile(`^</?(p|h[1-6]|pre|code|blockquote|ul|ol|li|hr|br|em|strong)>$|^</a>$|^<ol start="\d+">$|^<code class="language-[\w+#.-]+">$|^<a href="(https?|mailto):[^"<>]*"( title="[^"<>]*")? rel="nofollow">$`)
+)
+
+func TestRenderOnlyAllowedTags(t *testing.T) {
+	pieces := []string{
+		"[", "]", "(", ")", "<", ">", "!", "*", "_", "`", "\\", "\"", "'", "#", "-", "1.", " ", "\n", "\n\n",
+		"javascript:", "https://a.com", "mailto:", "a", "<script>", "onerror=", "&", "> ", "```",
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		var b strings.Builder
+		for n := rng.Intn(30); n >= 0; n-- {
+			b.WriteString(pieces[rng.Intn(len(pieces))])
+		}
+
+		in := b.String()
+		for _, tag := range anyTag.FindAllString(Render(in), -1) {
+			if !allowedTag.MatchString(tag) {
+				t.Fatalf("Render(%q) produced %q", in, tag)
+			}
+		}
+	}
+}
+
+func TestRenderPathological(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"unclosed link destinations", strings.Repeat("[a](", 40000)},
+		{"unclosed angle destinations", strings.Repeat("[a](<", 40000)},
+		{"unclosed titles", strings.Repeat(`[a](b "`, 40000)},
+		{"unclosed brackets", strings.Repeat("[", 100000)},
+		{"unclosed emphasis", strings.Repeat("*a ", 100000)},
+		{"strong runs", strings.Repeat("**", 100000)},
+		{"backticks", strings.Repeat("`", 100000)},
+		{"deep quotes", strings.Repeat(">", 100000)},
+		{"deep lists", strings.Repeat("- ", 50000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			Render(tt.in)
+			if d := time.Since(start); d > 2*time.Second {
+				t.Errorf("rendering %d bytes took %v", len(tt.in), d)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Package media inspects uploaded images and videos and makes thumbnails of
+// images.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+const (
+	KindImage = "image"
+	KindVideo = "video"
+)
+
+// MaxPixels bounds the size of images that are decoded, so that a small file
+// declaring huge dimensions cannot exhaust memory: decoding takes up to 4
+// bytes per pixel, about 100 MB at this limit.
+const MaxPixels = 25_000_000
+
+var (
+	ErrUnsupported = errors.New("unsupported media type")
+	ErrTooLarge    = errors.New("image dimensions are too large")
+)
+
+var kinds = map[string]string{
+	"image/jpeg": KindImage,
+	"image/png":  KindImage,
+	"image/gif":  KindImage,
+	"video/mp4":  KindVideo,
+	"video/webm": KindVideo,
+}
+
+// Detect sniffs the content type of a file from its first 512 bytes and
+// returns it with its kind. Only the types above are accepted.
+func Detect(head []byte) (contentType, kind string, err error) {
+	contentType = http.DetectContentType(head)
+	kind, ok := kinds[contentType]
+	if !ok {
+		return "", "", ErrUnsupported
+	}
+	return contentType, kind, nil
+}
+
+// Thumbnail decodes an image and returns a JPEG thumbnail that fits in a
+// size×size square, along with the dimensions of the original image. Images
+// smaller than the square are not enlarged.
+func Thumbnail(data []byte, size int) (thumb []byte, width, height int, err error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, 0, 0, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleDown(img, size), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, 0, 0, err
+	}
+
+	return buf.Bytes(), cfg.Width, cfg.Height, nil
+}
+
+// scaleDown resizes an image to fit in a size×size square. Each pixel of the
+// result averages a grid of samples of the source area it covers.
+func scaleDown(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	dw, dh := w, h
+	if w > size || h > size {
+		if w >= h {
+			dw, dh = size, max(1, h*size/w)
+		} else {
+			dw, dh = max(1, w*size/h), size
+		}
+	}
+
+	const samples = 4
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var r, g, bl, a uint32
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					px := b.Min.X + (x*samples+sx)*w/(dw*samples)
+					py := b.Min.Y + (y*samples+sy)*h/(dh*samples)
+					cr, cg, cb, ca := src.At(px, py).RGBA()
+					r, g, bl, a = r+cr, g+cg, bl+cb, a+ca
+				}
+			}
+			n := uint32(samples * samples)
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	// JPEG không có kênh alpha: phủ nền trắng cho ảnh trong suốt
+	out := image.NewRGBA(dst.Bounds())
+	for i := 0; i < len(out.Pix); i += 4 {
+		a := uint32(dst.Pix[i+3])
+		for c := 0; c < 3; c++ {
+			out.Pix[i+c] = uint8((uint32(dst.Pix[i+c])*255 + 255*(255-a)) / 255)
+		}
+		out.Pix[i+3] = 255
+	}
+	return out
+}
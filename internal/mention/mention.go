@@ -0,0 +1,45 @@
+package mention
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// Candidate is an @username found in a text. Offset and Length are counted in
+// characters (Unicode code points) and include the leading "@".
+type Candidate struct {
+	Username string
+	Offset   int
+	Length   int
+}
+
+var mentionRegex = regexp.MustCompile(`@([A-Za-z0-9_]{1,100})`)
+
+// Parse returns the @username mentions of a text in order of appearance. An
+// "@" preceded by a letter, digit or underscore (e.g. in an email address)
+// does not start a mention.
+func Parse(text string) []Candidate {
+	candidates := []Candidate{}
+
+	for _, loc := range mentionRegex.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if start > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(text[:start])
+			if isWordRune(prev) {
+				continue
+			}
+		}
+
+		candidates = append(candidates, Candidate{
+			Username: text[loc[2]:loc[3]],
+			Offset:   utf8.RuneCountInString(text[:start]),
+			Length:   utf8.RuneCountInString(text[start:end]),
+		})
+	}
+
+	return candidates
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
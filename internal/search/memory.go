@@ -0,0 +1,220 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"backendwithgo/internal/store"
+)
+
+const (
+	// BM25 parameters: k1 limits how much repeating a term raises the score
+	// and b how much longer posts are penalized.
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// titleBoost counts a term in the title as this many in the content.
+	titleBoost = 3
+)
+
+// ErrTooManyDocuments is returned by Load when the posts do not fit in the
+// index.
+var ErrTooManyDocuments = errors.New("too many posts for the in-memory search index")
+
+// Memory is an inverted index of posts held in memory and ranked with BM25.
+// It is not persisted: Load fills it from the database when the API starts,
+// and afterwards only the writes handled by this process reach it through
+// Index and Delete. It is therefore only correct when a single API instance
+// serves all writes, scheduled publishing and trash restores included: every
+// other instance would hold its own diverging copy and rank differently. With
+// several instances use MySQL, the default.
+type Memory struct {
+	mu       sync.RWMutex
+	docs     map[int64]*memoryDoc
+	postings map[string]map[int64]int
+	totalLen int
+}
+
+type memoryDoc struct {
+	authorID    int64
+	tags        []string
+	publishedAt time.Time
+	length      int
+	terms       map[string]int
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		docs:     make(map[int64]*memoryDoc),
+		postings: make(map[string]map[int64]int),
+	}
+}
+
+// DocumentSource lists the posts to index in batches, by ID.
+type DocumentSource interface {
+	GetDocuments(ctx context.Context, afterID int64, limit int) ([]store.SearchDocument, error)
+}
+
+// Load indexes all the posts of src and returns how many were indexed. It
+// stops with ErrTooManyDocuments once more than maxDocs posts were read.
+func (m *Memory) Load(ctx context.Context, src DocumentSource, maxDocs int) (int, error) {
+	const batchSize = 500
+
+	var afterID int64
+	count := 0
+	for {
+		docs, err := src.GetDocuments(ctx, afterID, batchSize)
+		if err != nil {
+			return count, err
+		}
+
+		for _, doc := range docs {
+			if err := m.Index(ctx, doc); err != nil {
+				return count, err
+			}
+			afterID = doc.PostID
+		}
+		count += len(docs)
+		if count > maxDocs {
+			return count, ErrTooManyDocuments
+		}
+
+		if len(docs) < batchSize {
+			return count, nil
+		}
+	}
+}
+
+func (m *Memory) Index(_ context.Context, doc store.SearchDocument) error {
+	terms := map[string]int{}
+	length := 0
+	for _, t := range tokenize(doc.Title) {
+		terms[t.term] += titleBoost
+		length += titleBoost
+	}
+	for _, t := range tokenize(doc.Content) {
+		terms[t.term]++
+		length++
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(doc.PostID)
+
+	m.docs[doc.PostID] = &memoryDoc{
+		authorID:    doc.AuthorID,
+		tags:        doc.Tags,
+		publishedAt: doc.PublishedAt,
+		length:      length,
+		terms:       terms,
+	}
+	m.totalLen += length
+	for term, tf := range terms {
+		if m.postings[term] == nil {
+			m.postings[term] = make(map[int64]int)
+		}
+		m.postings[term][doc.PostID] = tf
+	}
+
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, postID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(postID)
+	return nil
+}
+
+func (m *Memory) remove(postID int64) {
+	doc, ok := m.docs[postID]
+	if !ok {
+		return
+	}
+
+	for term := range doc.terms {
+		delete(m.postings[term], postID)
+		if len(m.postings[term]) == 0 {
+			delete(m.postings, term)
+		}
+	}
+	m.totalLen -= doc.length
+	delete(m.docs, postID)
+}
+
+func (m *Memory) Search(_ context.Context, q store.SearchQuery) ([]store.SearchHit, error) {
+	terms := Terms(q.Text)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hits := []store.SearchHit{}
+	if len(terms) == 0 || len(m.docs) == 0 {
+		return hits, nil
+	}
+
+	n := float64(len(m.docs))
+	avgLen := float64(m.totalLen) / n
+
+	scores := map[int64]float64{}
+	for _, term := range terms {
+		postings := m.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range postings {
+			doc := m.docs[id]
+			if !doc.matches(q) {
+				continue
+			}
+			f := float64(tf)
+			scores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(doc.length)/avgLen))
+		}
+	}
+
+	for id, score := range scores {
+		hits = append(hits, store.SearchHit{PostID: id, Score: score})
+	}
+	slices.SortFunc(hits, func(a, b store.SearchHit) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		// cùng điểm thì bài mới hơn đứng trước
+		if a.PostID > b.PostID {
+			return -1
+		}
+		return 1
+	})
+
+	if len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, nil
+}
+
+func (d *memoryDoc) matches(q store.SearchQuery) bool {
+	if q.AuthorID != 0 && d.authorID != q.AuthorID {
+		return false
+	}
+	if q.Tag != "" && !slices.Contains(d.tags, q.Tag) {
+		return false
+	}
+	if q.Since != nil && d.publishedAt.Before(*q.Since) {
+		return false
+	}
+	if q.Until != nil && !d.publishedAt.Before(*q.Until) {
+		return false
+	}
+	return true
+}
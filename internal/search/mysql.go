@@ -0,0 +1,35 @@
+package search
+
+import (
+	"context"
+
+	"backendwithgo/internal/store"
+)
+
+// FullTextSearcher searches posts with a database full-text index.
+type FullTextSearcher interface {
+	FullText(context.Context, store.SearchQuery) ([]store.SearchHit, error)
+}
+
+// MySQL searches with the FULLTEXT index of the posts table. MySQL keeps that
+// index up to date itself, so Index and Delete do nothing. Short words (under
+// innodb_ft_min_token_size) and stopwords are not searchable.
+type MySQL struct {
+	db FullTextSearcher
+}
+
+func NewMySQL(db FullTextSearcher) *MySQL {
+	return &MySQL{db: db}
+}
+
+func (s *MySQL) Index(context.Context, store.SearchDocument) error {
+	return nil
+}
+
+func (s *MySQL) Delete(context.Context, int64) error {
+	return nil
+}
+
+func (s *MySQL) Search(ctx context.Context, q store.SearchQuery) ([]store.SearchHit, error) {
+	return s.db.FullText(ctx, q)
+}
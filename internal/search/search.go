@@ -0,0 +1,154 @@
+// Package search finds posts by relevance to a text query.
+package search
+
+import (
+	"context"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"backendwithgo/internal/store"
+)
+
+// Engine keeps a search index of published posts. MySQL relies on the
+// FULLTEXT index of the posts table and needs no syncing; Memory is an index
+// held by the API process, for single-instance deployments only.
+type Engine interface {
+	// Index adds a post to the index, replacing the previous version of it.
+	Index(ctx context.Context, doc store.SearchDocument) error
+	// Delete removes a post from the index. Deleting a post that is not
+	// indexed is not an error.
+	Delete(ctx context.Context, postID int64) error
+	// Search returns up to q.Limit posts matching q, best matches first.
+	// Results are not filtered by visibility.
+	Search(ctx context.Context, q store.SearchQuery) ([]store.SearchHit, error)
+}
+
+type token struct {
+	start, end int
+	term       string
+}
+
+// tokenize splits text into lowercased words of letters and digits, with
+// their byte offsets in text.
+func tokenize(text string) []token {
+	tokens := []token{}
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			tokens = append(tokens, token{start: start, end: i, term: strings.ToLower(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{start: start, end: len(text), term: strings.ToLower(text[start:])})
+	}
+	return tokens
+}
+
+// Terms returns the distinct search terms of a query, in order.
+func Terms(text string) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, t := range tokenize(text) {
+		if !seen[t.term] {
+			seen[t.term] = true
+			terms = append(terms, t.term)
+		}
+	}
+	return terms
+}
+
+// Highlight returns an HTML-escaped excerpt of text of about size characters
+// around the first match of terms, with matches wrapped in <mark>. Text
+// without a match is excerpted from its start.
+func Highlight(text string, terms []string, size int) string {
+	match := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		match[t] = true
+	}
+
+	tokens := tokenize(text)
+	first := -1
+	for _, t := range tokens {
+		if match[t.term] {
+			first = t.start
+			break
+		}
+	}
+
+	// cửa sổ bắt đầu trước từ khớp đầu tiên khoảng một phần tư kích thước
+	from, to := 0, len(text)
+	if utf8.RuneCountInString(text) > size {
+		if first > 0 {
+			from = moveBack(text, first, size/4)
+		}
+		to = moveForward(text, from, size)
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+
+	pos := from
+	for _, t := range tokens {
+		if t.start < from || t.end > to || !match[t.term] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:t.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[t.start:t.end]))
+		b.WriteString("</mark>")
+		pos = t.end
+	}
+	b.WriteString(html.EscapeString(text[pos:to]))
+
+	if to < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// moveBack returns the offset n runes before offset i, moved forward to the
+// start of a word so the excerpt does not begin mid-word.
+func moveBack(text string, i, n int) int {
+	for ; n > 0 && i > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(text[:i])
+		i -= size
+	}
+	for i > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:i])
+		if unicode.IsSpace(r) {
+			break
+		}
+		i -= size
+	}
+	return i
+}
+
+// moveForward returns the offset n runes after offset i, moved back to the
+// end of a word when possible.
+func moveForward(text string, i, n int) int {
+	start := i
+	for ; n > 0 && i < len(text); n-- {
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+	if i >= len(text) {
+		return len(text)
+	}
+	for j := i; j > start; {
+		r, size := utf8.DecodeLastRuneInString(text[:j])
+		if unicode.IsSpace(r) {
+			return j - size
+		}
+		j -= size
+	}
+	return i
+}
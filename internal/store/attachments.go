@@ -0,0 +1,237 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Attachment struct {
+	ID          int64  `json:"id"`
+	UserID      int64  `json:"user_id"`
+	PostID      *int64 `json:"post_id"`
+	Position    int    `json:"position"`
+	Kind        string `json:"kind"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	AltText     string `json:"alt_text"`
+	// StorageKey and ThumbnailKey locate the file and its thumbnail in the
+	// blob store; clients get signed URL and ThumbnailURL links instead.
+	StorageKey   string    `json:"-"`
+	ThumbnailKey *string   `json:"-"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type AttachmentStore struct {
+	db *sql.DB
+}
+
+const attachmentColumns = `id, user_id, post_id, position, kind, content_type, size, width, height, alt_text,
+	storage_key, thumbnail_key, created_at`
+
+func scanAttachment(row rowScanner, a *Attachment) error {
+	var postID sql.NullInt64
+	var thumbnailKey sql.NullString
+
+	err := row.Scan(
+		&a.ID,
+		&a.UserID,
+		&postID,
+		&a.Position,
+		&a.Kind,
+		&a.ContentType,
+		&a.Size,
+		&a.Width,
+		&a.Height,
+		&a.AltText,
+		&a.StorageKey,
+		&thumbnailKey,
+		&a.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if postID.Valid {
+		a.PostID = &postID.Int64
+	}
+	if thumbnailKey.Valid {
+		a.ThumbnailKey = &thumbnailKey.String
+	}
+	return nil
+}
+
+// Create records an uploaded file. It is not attached to a post until the
+// post referencing it is created.
+func (s *AttachmentStore) Create(ctx context.Context, a *Attachment) error {
+	query := `
+		INSERT INTO attachments (user_id, kind, content_type, size, width, height, alt_text, storage_key, thumbnail_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query,
+		a.UserID, a.Kind, a.ContentType, a.Size, a.Width, a.Height, a.AltText, a.StorageKey, a.ThumbnailKey)
+	if err != nil {
+		return err
+	}
+
+	a.ID, err = res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	return s.db.QueryRowContext(ctx, `SELECT created_at FROM attachments WHERE id = ?`, a.ID).Scan(&a.CreatedAt)
+}
+
+func (s *AttachmentStore) GetByID(ctx context.Context, id int64) (*Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM attachments WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	var a Attachment
+	if err := scanAttachment(s.db.QueryRowContext(ctx, query, id), &a); err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// UpdateAltText changes the alt text of an attachment of the user. It
+// returns sql.ErrNoRows when the user has no such attachment.
+func (s *AttachmentStore) UpdateAltText(ctx context.Context, userID, id int64, altText string) error {
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `UPDATE attachments SET alt_text = ? WHERE id = ? AND user_id = ?`, altText, id, userID)
+	if err != nil {
+		return err
+	}
+
+	// MySQL không tính dòng có giá trị không đổi, nên kiểm tra tồn tại riêng
+	if rows, err := res.RowsAffected(); err != nil || rows > 0 {
+		return err
+	}
+
+	var exists bool
+	err = s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM attachments WHERE id = ? AND user_id = ?)`, id, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetForPosts loads the attachments of a set of posts, in order.
+func (s *AttachmentStore) GetForPosts(ctx context.Context, postIDs []int64) (map[int64][]Attachment, error) {
+	attachments := make(map[int64][]Attachment, len(postIDs))
+	if len(postIDs) == 0 {
+		return attachments, nil
+	}
+
+	query := `SELECT ` + attachmentColumns + `
+		FROM attachments
+		WHERE post_id IN (` + placeholders(len(postIDs)) + `)
+		ORDER BY post_id, position`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, int64Args(postIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a Attachment
+		if err := scanAttachment(rows, &a); err != nil {
+			return nil, err
+		}
+		attachments[*a.PostID] = append(attachments[*a.PostID], a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// DeleteUnattached deletes up to limit attachments that are not attached to
+// any post and are older than maxAge: uploads never used in a post and
+// attachments of purged posts. It returns them so their files can be
+// removed. Rows locked by a cleanup running elsewhere are skipped.
+func (s *AttachmentStore) DeleteUnattached(ctx context.Context, maxAge time.Duration, limit int) ([]Attachment, error) {
+	attachments := []Attachment{}
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, `SELECT `+attachmentColumns+`
+			FROM attachments
+			WHERE post_id IS NULL AND created_at < NOW() - INTERVAL ? SECOND
+			ORDER BY created_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED`, int64(maxAge.Seconds()), limit)
+		if err != nil {
+			return err
+		}
+
+		ids := []int64{}
+		for rows.Next() {
+			var a Attachment
+			if err := scanAttachment(rows, &a); err != nil {
+				rows.Close()
+				return err
+			}
+			attachments = append(attachments, a)
+			ids = append(ids, a.ID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM attachments WHERE id IN (`+placeholders(len(ids))+`)`, int64Args(ids)...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// attachToPost attaches uploads of the author to a new post in the given
+// order. It returns ErrInvalidAttachment when one of them is not an unused
+// upload of the author.
+func attachToPost(ctx context.Context, tx *sql.Tx, post *Post) error {
+	for i, a := range post.Attachments {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE attachments SET post_id = ?, position = ?
+			WHERE id = ? AND user_id = ? AND post_id IS NULL`,
+			post.ID, i, a.ID, post.UserID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrInvalidAttachment
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type BlockStore struct {
+	db *sql.DB
+}
+
+// Block makes blockerID block blockedID. Follows and follow requests between
+// the two users are removed in both directions. Blocking again is a no-op.
+func (s *BlockStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `INSERT IGNORE INTO user_blocks (blocker_id, blocked_id, created_at) VALUES (?, ?, NOW())`, blockerID, blockedID)
+		if err != nil {
+			return err
+		}
+
+		for _, table := range []string{"followers", "follow_requests"} {
+			_, err := tx.ExecContext(ctx, `
+				DELETE FROM `+table+`
+				WHERE (user_id = ? AND follower_id = ?) OR (user_id = ? AND follower_id = ?)`,
+				blockerID, blockedID, blockedID, blockerID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Unblock returns sql.ErrNoRows when blockerID does not block blockedID.
+func (s *BlockStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	query := `DELETE FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// IsBlocked reports whether either user blocks the other.
+func (s *BlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+		)`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	var blocked bool
+	err := s.db.QueryRowContext(ctx, query, userID, otherID, otherID, userID).Scan(&blocked)
+	return blocked, err
+}
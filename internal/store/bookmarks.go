@@ -0,0 +1,270 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Bookmark is a post saved by a user, optionally filed in one of their
+// collections.
+type Bookmark struct {
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"user_id"`
+	PostID       int64     `json:"post_id"`
+	CollectionID *int64    `json:"collection_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// BookmarkCollection is a named, private group of bookmarks.
+type BookmarkCollection struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Count     int       `json:"count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BookmarkPage is a page of bookmarked posts, most recently saved first.
+type BookmarkPage struct {
+	Posts      []PostWithData `json:"posts"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+type BookmarkStore struct {
+	db *sql.DB
+}
+
+// Save bookmarks a post, or moves an existing bookmark to another collection.
+// It returns sql.ErrNoRows when the collection is not one of the user's.
+func (s *BookmarkStore) Save(ctx context.Context, bookmark *Bookmark) error {
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	if bookmark.CollectionID != nil {
+		var owned bool
+		err := s.db.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM bookmark_collections WHERE id = ? AND user_id = ?)`,
+			*bookmark.CollectionID, bookmark.UserID).Scan(&owned)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return sql.ErrNoRows
+		}
+	}
+
+	query := `
+		INSERT INTO bookmarks (user_id, post_id, collection_id, created_at)
+		VALUES (?, ?, ?, NOW())
+		ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id), collection_id = VALUES(collection_id)`
+
+	res, err := s.db.ExecContext(ctx, query, bookmark.UserID, bookmark.PostID, bookmark.CollectionID)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	bookmark.ID = id
+
+	return s.db.QueryRowContext(ctx, `SELECT created_at FROM bookmarks WHERE id = ?`, id).Scan(&bookmark.CreatedAt)
+}
+
+func (s *BookmarkStore) Delete(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM bookmarks WHERE user_id = ? AND post_id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetPosts lists the bookmarked posts of a user, optionally only those of one
+// collection. Posts the user can no longer see are left out.
+func (s *BookmarkStore) GetPosts(ctx context.Context, userID int64, collectionID *int64, cq CursorQuery) (*BookmarkPage, error) {
+	args := []any{userID, collectionID, collectionID, userID, userID, userID, userID, userID}
+
+	keyset := "TRUE"
+	if cq.Cursor != "" {
+		values, err := decodeCursor(cq.Cursor, 2)
+		if err != nil {
+			return nil, err
+		}
+		keyset = "(b.created_at < FROM_UNIXTIME(?) OR (b.created_at = FROM_UNIXTIME(?) AND b.id < ?))"
+		args = append(args, values[0], values[0], values[1])
+	}
+	args = append(args, cq.Limit+1)
+
+	query := `
+		SELECT
+			p.id,
+			p.user_id,
+			p.title,
+			p.content,
+			p.created_at,
+			p.version,
+			` + postTagsJSON + `,
+			u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.is_deleted = FALSE),
+			b.id,
+			UNIX_TIMESTAMP(b.created_at)
+		FROM bookmarks b
+		JOIN posts p ON p.id = b.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE b.user_id = ? AND (? IS NULL OR b.collection_id = ?)
+			AND` + visibleToViewer + `
+			AND ` + keyset + `
+		ORDER BY b.created_at DESC, b.id DESC
+		LIMIT ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &BookmarkPage{Posts: []PostWithData{}}
+	var lastID, lastSaved int64
+	for rows.Next() {
+		var post PostWithData
+		var tagsSQL sql.NullString
+		var bookmarkID, savedAt int64
+
+		err := rows.Scan(
+			&post.ID,
+			&post.UserID,
+			&post.Title,
+			&post.Content,
+			&post.CreatedAt,
+			&post.Version,
+			&tagsSQL,
+			&post.User.Username,
+			&post.CommentCount,
+			&bookmarkID,
+			&savedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.Posts) == cq.Limit {
+			cursor := encodeCursor(lastSaved, lastID)
+			page.NextCursor = &cursor
+			break
+		}
+
+		post.Tags = []string{}
+		if tagsSQL.Valid && tagsSQL.String != "" {
+			if err := json.Unmarshal([]byte(tagsSQL.String), &post.Tags); err != nil {
+				return nil, err
+			}
+		}
+
+		post.User.ID = post.UserID
+		post.Comments = []Comment{}
+		page.Posts = append(page.Posts, post)
+		lastID, lastSaved = bookmarkID, savedAt
+	}
+
+	return page, rows.Err()
+}
+
+// CreateCollection returns ErrConflict when the user already has a
+// collection with that name.
+func (s *BookmarkStore) CreateCollection(ctx context.Context, collection *BookmarkCollection) error {
+	query := `INSERT INTO bookmark_collections (user_id, name, created_at) VALUES (?, ?, NOW())`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, collection.UserID, collection.Name)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return ErrConflict
+		}
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	collection.ID = id
+
+	return s.db.QueryRowContext(ctx, `SELECT created_at FROM bookmark_collections WHERE id = ?`, id).Scan(&collection.CreatedAt)
+}
+
+func (s *BookmarkStore) GetCollections(ctx context.Context, userID int64) ([]BookmarkCollection, error) {
+	query := `
+		SELECT bc.id, bc.user_id, bc.name, bc.created_at,
+			(SELECT COUNT(*) FROM bookmarks b WHERE b.collection_id = bc.id)
+		FROM bookmark_collections bc
+		WHERE bc.user_id = ?
+		ORDER BY bc.name`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []BookmarkCollection{}
+	for rows.Next() {
+		var c BookmarkCollection
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.CreatedAt, &c.Count); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+
+	return collections, rows.Err()
+}
+
+// DeleteCollection removes a collection of the user. Its bookmarks are kept,
+// outside of any collection.
+func (s *BookmarkStore) DeleteCollection(ctx context.Context, userID, collectionID int64) error {
+	query := `DELETE FROM bookmark_collections WHERE id = ? AND user_id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, collectionID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
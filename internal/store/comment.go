@@ -3,76 +3,418 @@ package store
 import (
 	"context"
 	"database/sql"
+	"net/http"
+	"slices"
+	"strconv"
 	"time"
 )
 
+// DeletedCommentContent replaces the content of a deleted comment that still
+// has replies, so the conversation below it keeps its place in the tree.
+const DeletedCommentContent = "[deleted]"
+
 type Comment struct {
-	ID        int64    `json:"id"`
-	PostID    int64    `json:"postid"`
-	Content   string   `json:"content"`
-	Tags      []string `json:"tags"`
-	UserID    int64    `json:"userid"`
-	CreatedAt int64    `json:"createdat"`
-	User      User     `json:"user"`
+	ID        int64     `json:"id"`
+	PostID    int64     `json:"postid"`
+	ParentID  *int64    `json:"parent_id"`
+	Depth     int       `json:"depth"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags"`
+	UserID    int64     `json:"userid"`
+	IsDeleted bool      `json:"is_deleted"`
+	CreatedAt int64     `json:"createdat"`
+	UpdatedAt int64     `json:"updatedat"`
+	User      User      `json:"user"`
+	Mentions  []Mention `json:"mentions"`
+	Reactions Reactions `json:"reactions"`
+	// ReplyCount is the number of direct replies. When not all of them are
+	// in Replies, RepliesCursor is the "after" value to load the next ones.
+	ReplyCount    int       `json:"reply_count"`
+	Replies       []Comment `json:"replies"`
+	RepliesCursor *int64    `json:"replies_cursor"`
+}
+
+// CommentTreeQuery controls how much of a comment tree is loaded: Depth
+// levels of replies below the requested comments, and at most Replies
+// replies per comment on each level.
+type CommentTreeQuery struct {
+	Depth   int `json:"depth" validate:"gte=0,lte=10"`
+	Replies int `json:"replies" validate:"gte=1,lte=50"`
+	After   int64
+}
+
+func (tq *CommentTreeQuery) Parse(r *http.Request) (CommentTreeQuery, error) {
+	qs := r.URL.Query()
+
+	depth := qs.Get("depth")
+	if depth != "" {
+		d, err := strconv.Atoi(depth)
+		if err != nil {
+			return *tq, err
+		}
+		tq.Depth = d
+	}
+
+	replies := qs.Get("replies")
+	if replies != "" {
+		n, err := strconv.Atoi(replies)
+		if err != nil {
+			return *tq, err
+		}
+		tq.Replies = n
+	}
+
+	after := qs.Get("after")
+	if after != "" {
+		a, err := strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return *tq, err
+		}
+		tq.After = a
+	}
+
+	return *tq, nil
+}
+
+const (
+	CommentSortNewest = "newest"
+	CommentSortOldest = "oldest"
+	CommentSortTop    = "top"
+)
+
+// commentSorts numbers the sorts in page cursors, so that a cursor is only
+// accepted with the sort it was made for.
+var commentSorts = []string{CommentSortNewest, CommentSortOldest, CommentSortTop}
+
+// CommentPageQuery selects a page of the top-level comments of a post.
+// Cursor is the NextCursor of the previous page.
+type CommentPageQuery struct {
+	Sort   string `json:"sort" validate:"oneof=newest oldest top"`
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Cursor string `json:"cursor"`
+}
+
+func (pq *CommentPageQuery) Parse(r *http.Request) (CommentPageQuery, error) {
+	qs := r.URL.Query()
+
+	sort := qs.Get("sort")
+	if sort != "" {
+		pq.Sort = sort
+	}
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return *pq, err
+		}
+		pq.Limit = l
+	}
+
+	pq.Cursor = qs.Get("cursor")
+
+	return *pq, nil
+}
+
+// CommentPage is a page of top-level comments. Total counts every comment of
+// the post, replies included, and NextCursor is nil on the last page.
+type CommentPage struct {
+	Comments   []Comment `json:"comments"`
+	Total      int       `json:"total"`
+	NextCursor *string   `json:"next_cursor"`
 }
 
 type Commentstore struct {
 	db *sql.DB
 }
 
-// Lấy comment theo postID
-func (s *Commentstore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
-	query := `
-    SELECT 
-        c.id, 
-        c.post_id, 
-        c.user_id, 
-        c.content, 
-        UNIX_TIMESTAMP(c.created_at), 
-        u.username, 
-        u.id
-    FROM comments c
-    JOIN users u ON u.id = c.user_id
-    WHERE c.post_id = ?
-    ORDER BY c.created_at DESC;
-`
+const commentColumns = `
+	c.id,
+	c.post_id,
+	c.parent_id,
+	c.depth,
+	c.user_id,
+	c.content,
+	c.is_deleted,
+	UNIX_TIMESTAMP(c.created_at) AS created_at,
+	UNIX_TIMESTAMP(c.updated_at) AS updated_at,
+	u.username,
+	(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id) AS reply_count`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// commentScore ranks comments for the "top" sort by their reactions.
+const commentScore = `(SELECT COUNT(*) FROM reactions rc WHERE rc.comment_id = c.id)`
+
+func scanComment(row rowScanner, c *Comment, extra ...any) error {
+	var parentID sql.NullInt64
+	dest := []any{
+		&c.ID,
+		&c.PostID,
+		&parentID,
+		&c.Depth,
+		&c.UserID,
+		&c.Content,
+		&c.IsDeleted,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+		&c.User.Username,
+		&c.ReplyCount,
+	}
+
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	if parentID.Valid {
+		c.ParentID = &parentID.Int64
+	}
+	c.User.ID = c.UserID
+
+	// không lộ tác giả của comment đã xoá
+	if c.IsDeleted {
+		c.Content = DeletedCommentContent
+		c.UserID = 0
+		c.User = User{}
+	}
+	c.Replies = []Comment{}
+	c.Reactions = emptyReactions()
+
+	return nil
+}
+
+// Lấy một trang comment gốc theo postID, kèm cây trả lời
+func (s *Commentstore) GetByPostID(ctx context.Context, postID int64, pq CommentPageQuery, tq CommentTreeQuery) (*CommentPage, error) {
+	// cursor mang theo cách sắp xếp đã tạo ra nó
+	sortCode := slices.Index(commentSorts, pq.Sort)
+	if sortCode < 0 {
+		sortCode = 0
+	}
+
+	var cursor []int64
+	if pq.Cursor != "" {
+		values, err := decodeCursor(pq.Cursor, 3)
+		if err != nil {
+			return nil, err
+		}
+		if values[0] != int64(sortCode) {
+			return nil, ErrInvalidCursor
+		}
+		cursor = values[1:]
+	}
+
+	args := []any{postID}
+	var query string
+	if pq.Sort == CommentSortTop {
+		// điểm được tính từ reactions nên không có index để keyset theo
+		where := "TRUE"
+		if cursor != nil {
+			where = "(t.score < ? OR (t.score = ? AND t.id < ?))"
+			args = append(args, cursor[0], cursor[0], cursor[1])
+		}
+
+		query = `
+		SELECT id, post_id, parent_id, depth, user_id, content, is_deleted, created_at, updated_at, username, reply_count, score
+		FROM (
+			SELECT ` + commentColumns + `, ` + commentScore + ` AS score
+			FROM comments c
+			JOIN users u ON u.id = c.user_id
+			WHERE c.post_id = ? AND c.parent_id IS NULL
+		) t
+		WHERE ` + where + `
+		ORDER BY t.score DESC, t.id DESC
+		LIMIT ?`
+	} else {
+		// newest và oldest đọc thẳng theo idx_comments_post_top
+		order := "c.created_at DESC, c.id DESC"
+		where := "(c.created_at < FROM_UNIXTIME(?) OR (c.created_at = FROM_UNIXTIME(?) AND c.id < ?))"
+		if pq.Sort == CommentSortOldest {
+			order = "c.created_at ASC, c.id ASC"
+			where = "(c.created_at > FROM_UNIXTIME(?) OR (c.created_at = FROM_UNIXTIME(?) AND c.id > ?))"
+		}
+		if cursor != nil {
+			args = append(args, cursor[0], cursor[0], cursor[1])
+		} else {
+			where = "TRUE"
+		}
+
+		query = `
+		SELECT ` + commentColumns + `, 0
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.post_id = ? AND c.parent_id IS NULL AND ` + where + `
+		ORDER BY ` + order + `
+		LIMIT ?`
+	}
+	args = append(args, pq.Limit+1)
 
 	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	comments := []Comment{}
+	scores := []int64{}
 	for rows.Next() {
 		var c Comment
-		c.User = User{}
-		err := rows.Scan(
-			&c.ID,
-			&c.PostID,
-			&c.UserID,
-			&c.Content,
-			&c.CreatedAt,
-			&c.User.Username,
-			&c.User.ID,
-		)
-		if err != nil {
+		var score int64
+		if err := scanComment(rows, &c, &score); err != nil {
 			return nil, err
 		}
 		comments = append(comments, c)
+		scores = append(scores, score)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &CommentPage{Comments: comments}
+	if len(comments) > pq.Limit {
+		page.Comments = comments[:pq.Limit]
+		last := page.Comments[pq.Limit-1]
+
+		key := last.CreatedAt
+		if pq.Sort == CommentSortTop {
+			key = scores[pq.Limit-1]
+		}
+		next := encodeCursor(int64(sortCode), key, last.ID)
+		page.NextCursor = &next
+	}
+
+	err = s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM comments WHERE post_id = ? AND is_deleted = FALSE`, postID).Scan(&page.Total)
+	if err != nil {
+		return nil, err
 	}
 
-	return comments, nil
+	parents := make([]*Comment, 0, len(page.Comments))
+	for i := range page.Comments {
+		parents = append(parents, &page.Comments[i])
+	}
+
+	if err := s.loadReplies(ctx, parents, 0, tq); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// GetReplies loads the replies of a comment after the tq.After cursor, oldest
+// first, with their own replies down to tq.Depth levels.
+func (s *Commentstore) GetReplies(ctx context.Context, parent *Comment, tq CommentTreeQuery) error {
+	parent.Replies = []Comment{}
+	parent.RepliesCursor = nil
+
+	depth := tq.Depth
+	if depth < 1 {
+		depth = 1
+	}
+	tq.Depth = depth
+
+	return s.loadReplies(ctx, []*Comment{parent}, tq.After, tq)
+}
+
+// loadReplies fills in the replies of the given comments level by level.
+// Comments whose replies are cut off by tq.Replies or tq.Depth get a cursor
+// to load the rest.
+func (s *Commentstore) loadReplies(ctx context.Context, parents []*Comment, after int64, tq CommentTreeQuery) error {
+	for level := 0; level < tq.Depth && len(parents) > 0; level++ {
+		ids := make([]int64, 0, len(parents))
+		for _, p := range parents {
+			if p.ReplyCount > 0 {
+				ids = append(ids, p.ID)
+			}
+		}
+
+		children, err := s.getChildren(ctx, ids, after, tq.Replies+1)
+		if err != nil {
+			return err
+		}
+		after = 0
+
+		next := []*Comment{}
+		for _, p := range parents {
+			replies := children[p.ID]
+			if len(replies) > tq.Replies {
+				replies = replies[:tq.Replies]
+				cursor := replies[len(replies)-1].ID
+				p.RepliesCursor = &cursor
+			}
+			if replies == nil {
+				replies = []Comment{}
+			}
+
+			p.Replies = replies
+			for i := range p.Replies {
+				next = append(next, &p.Replies[i])
+			}
+		}
+		parents = next
+	}
+
+	// sâu hơn giới hạn: chỉ trả cursor để tải tiếp
+	for _, p := range parents {
+		if p.ReplyCount > 0 && len(p.Replies) == 0 && p.RepliesCursor == nil {
+			var cursor int64
+			p.RepliesCursor = &cursor
+		}
+	}
+
+	return nil
+}
+
+// getChildren returns at most limit replies of each parent with an ID greater
+// than after, keyed by parent ID.
+func (s *Commentstore) getChildren(ctx context.Context, parentIDs []int64, after int64, limit int) (map[int64][]Comment, error) {
+	children := map[int64][]Comment{}
+	if len(parentIDs) == 0 {
+		return children, nil
+	}
+
+	query := `
+		SELECT id, post_id, parent_id, depth, user_id, content, is_deleted, created_at, updated_at, username, reply_count
+		FROM (
+			SELECT ` + commentColumns + `,
+				ROW_NUMBER() OVER (PARTITION BY c.parent_id ORDER BY c.id) AS rn
+			FROM comments c
+			JOIN users u ON u.id = c.user_id
+			WHERE c.parent_id IN (` + placeholders(len(parentIDs)) + `) AND c.id > ?
+		) t
+		WHERE rn <= ?
+		ORDER BY parent_id, rn`
+
+	args := append(int64Args(parentIDs), after, limit)
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c Comment
+		if err := scanComment(rows, &c); err != nil {
+			return nil, err
+		}
+		children[*c.ParentID] = append(children[*c.ParentID], c)
+	}
+
+	return children, rows.Err()
 }
 
 // Tạo comment mới
 func (s *Commentstore) Create(ctx context.Context, comment *Comment) error {
 	query := `
-		INSERT INTO comments (post_id, user_id, content, created_at)
-		VALUES (?, ?, ?, NOW())
+		INSERT INTO comments (post_id, parent_id, depth, user_id, content, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, NOW(), NOW())
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
@@ -82,6 +424,8 @@ func (s *Commentstore) Create(ctx context.Context, comment *Comment) error {
 		ctx,
 		query,
 		comment.PostID,
+		comment.ParentID,
+		comment.Depth,
 		comment.UserID,
 		comment.Content,
 	)
@@ -98,6 +442,99 @@ func (s *Commentstore) Create(ctx context.Context, comment *Comment) error {
 
 	// Gán created_at theo timestamp hiện tại
 	comment.CreatedAt = time.Now().Unix()
+	comment.UpdatedAt = comment.CreatedAt
+	comment.Replies = []Comment{}
+	comment.Reactions = emptyReactions()
 
 	return nil
 }
+
+func (s *Commentstore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	c := &Comment{}
+	if err := scanComment(s.db.QueryRowContext(ctx, query, id), c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (s *Commentstore) Update(ctx context.Context, comment *Comment) error {
+	query := `UPDATE comments SET content = ?, updated_at = NOW() WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, query, comment.Content, comment.ID); err != nil {
+		return err
+	}
+
+	comment.UpdatedAt = time.Now().Unix()
+	return nil
+}
+
+// Delete removes a comment. A comment with replies is kept as a "[deleted]"
+// placeholder instead; placeholders left without replies are removed too.
+func (s *Commentstore) Delete(ctx context.Context, id int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		var parentID sql.NullInt64
+		var hasReplies bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT parent_id, EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+			FROM comments c
+			WHERE c.id = ? AND c.is_deleted = FALSE`, id).Scan(&parentID, &hasReplies)
+		if err != nil {
+			return err
+		}
+
+		if hasReplies {
+			_, err := tx.ExecContext(ctx, `UPDATE comments SET is_deleted = TRUE, content = '', updated_at = NOW() WHERE id = ?`, id)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, `DELETE FROM mentions WHERE comment_id = ?`, id)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, `DELETE FROM reactions WHERE comment_id = ?`, id)
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = ?`, id); err != nil {
+			return err
+		}
+
+		// dọn các placeholder phía trên không còn reply nào
+		for parentID.Valid {
+			var next sql.NullInt64
+			err := tx.QueryRowContext(ctx, `
+				SELECT parent_id FROM comments c
+				WHERE c.id = ? AND c.is_deleted = TRUE
+					AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)`, parentID.Int64).Scan(&next)
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = ?`, parentID.Int64); err != nil {
+				return err
+			}
+			parentID = next
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor packs the keyset values of the last row of a page into an
+// opaque token for the next request.
+func encodeCursor(values ...int64) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, strconv.FormatInt(v, 10))
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, ":")))
+}
+
+// decodeCursor unpacks a cursor made by encodeCursor with n values.
+func decodeCursor(cursor string, n int) ([]int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != n {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]int64, 0, n)
+	for _, p := range parts {
+		v, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+// CursorQuery selects a page of a keyset-paginated listing. Cursor is the
+// next_cursor returned with the previous page.
+type CursorQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Cursor string `json:"cursor"`
+}
+
+func (cq *CursorQuery) Parse(r *http.Request) (CursorQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return *cq, err
+		}
+		cq.Limit = l
+	}
+
+	cq.Cursor = qs.Get("cursor")
+
+	return *cq, nil
+}
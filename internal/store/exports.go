@@ -0,0 +1,481 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	ExportStatusPending = "pending"
+	ExportStatusReady   = "ready"
+	ExportStatusFailed  = "failed"
+	// ExportStatusExpired exports had their archive deleted once the
+	// download link expired.
+	ExportStatusExpired = "expired"
+)
+
+type DataExport struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	Status      string     `json:"status"`
+	FilePath    string     `json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// UserDataExport is everything stored about a user, as written to the
+// export archive.
+type UserDataExport struct {
+	Profile     ExportProfile      `json:"profile"`
+	Posts       []ExportPost       `json:"posts"`
+	Comments    []ExportComment    `json:"comments"`
+	Followers   []ExportFollow     `json:"followers"`
+	Following   []ExportFollow     `json:"following"`
+	Invitations []ExportInvitation `json:"invitations"`
+}
+
+type ExportProfile struct {
+	ID          int64     `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Email       string    `json:"email"`
+	IsActive    bool      `json:"is_active"`
+	IsPrivate   bool      `json:"is_private"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ExportPost struct {
+	ID        int64          `json:"id"`
+	Title     string         `json:"title"`
+	Content   string         `json:"content"`
+	Tags      []string       `json:"tags"`
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Revisions []PostRevision `json:"revisions"`
+}
+
+type ExportComment struct {
+	ID        int64     `json:"id"`
+	PostID    int64     `json:"post_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ExportFollow struct {
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ExportInvitation struct {
+	Expiry time.Time `json:"expiry"`
+}
+
+type ExportStore struct {
+	db *sql.DB
+}
+
+func (s *ExportStore) Create(ctx context.Context, export *DataExport) error {
+	query := `INSERT INTO data_exports (user_id, status, created_at) VALUES (?, ?, NOW())`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	export.Status = ExportStatusPending
+	res, err := s.db.ExecContext(ctx, query, export.UserID, export.Status)
+	if err != nil {
+		// uq_data_exports_pending: user đã có một export đang chờ
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return ErrConflict
+		}
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	export.ID = id
+
+	return s.db.QueryRowContext(ctx, `SELECT created_at FROM data_exports WHERE id = ?`, id).Scan(&export.CreatedAt)
+}
+
+func (s *ExportStore) GetByID(ctx context.Context, id int64) (*DataExport, error) {
+	query := `SELECT id, user_id, status, file_path, created_at, completed_at FROM data_exports WHERE id = ?`
+
+	return s.get(ctx, query, id)
+}
+
+// GetReady returns an export that is ready and was completed less than ttl
+// ago, or sql.ErrNoRows. Its age is measured by the database clock, like in
+// ExpireReady, so the link stops working when the archive is due for removal.
+func (s *ExportStore) GetReady(ctx context.Context, id int64, ttl time.Duration) (*DataExport, error) {
+	query := `
+		SELECT id, user_id, status, file_path, created_at, completed_at FROM data_exports
+		WHERE id = ? AND status = ? AND completed_at >= NOW() - INTERVAL ? SECOND`
+
+	return s.get(ctx, query, id, ExportStatusReady, int64(ttl.Seconds()))
+}
+
+func (s *ExportStore) get(ctx context.Context, query string, args ...any) (*DataExport, error) {
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	export := &DataExport{}
+	var completedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&export.FilePath,
+		&export.CreatedAt,
+		&completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		export.CompletedAt = &completedAt.Time
+	}
+
+	return export, nil
+}
+
+// ClaimPending marks the oldest pending export that nobody is building as
+// started and returns it, or sql.ErrNoRows when there is none. SKIP LOCKED
+// lets several API instances claim exports at the same time.
+func (s *ExportStore) ClaimPending(ctx context.Context) (*DataExport, error) {
+	export := &DataExport{}
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, `
+			SELECT id, user_id, status, file_path, created_at FROM data_exports
+			WHERE status = ? AND started_at IS NULL
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED`, ExportStatusPending).Scan(
+			&export.ID,
+			&export.UserID,
+			&export.Status,
+			&export.FilePath,
+			&export.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE data_exports SET started_at = NOW() WHERE id = ?`, export.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// FailStale marks as failed the exports started more than timeout ago and
+// still pending, whose build was interrupted by a restart, and returns how
+// many there were.
+func (s *ExportStore) FailStale(ctx context.Context, timeout time.Duration) (int64, error) {
+	query := `
+		UPDATE data_exports SET status = ?, error = 'export was interrupted', completed_at = NOW()
+		WHERE status = ? AND started_at < NOW() - INTERVAL ? SECOND`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, ExportStatusFailed, ExportStatusPending, int64(timeout.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ExpireReady marks as expired up to limit exports completed more than ttl
+// ago and returns them, with the path of the archive to delete.
+func (s *ExportStore) ExpireReady(ctx context.Context, ttl time.Duration, limit int) ([]DataExport, error) {
+	exports := []DataExport{}
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, `
+			SELECT id, user_id, file_path, created_at FROM data_exports
+			WHERE status = ? AND completed_at < NOW() - INTERVAL ? SECOND
+			ORDER BY id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED`, ExportStatusReady, int64(ttl.Seconds()), limit)
+		if err != nil {
+			return err
+		}
+
+		ids := []int64{}
+		for rows.Next() {
+			export := DataExport{Status: ExportStatusExpired}
+			if err := rows.Scan(&export.ID, &export.UserID, &export.FilePath, &export.CreatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			exports = append(exports, export)
+			ids = append(ids, export.ID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		args := append([]any{ExportStatusExpired}, int64Args(ids)...)
+		_, err = tx.ExecContext(ctx, `
+			UPDATE data_exports SET status = ?, file_path = ''
+			WHERE id IN (`+placeholders(len(ids))+`)`, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return exports, nil
+}
+
+// MarkReady returns when the export was completed, by the database clock.
+func (s *ExportStore) MarkReady(ctx context.Context, id int64, filePath string) (time.Time, error) {
+	query := `UPDATE data_exports SET status = ?, file_path = ?, completed_at = NOW() WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	var completedAt time.Time
+	if _, err := s.db.ExecContext(ctx, query, ExportStatusReady, filePath, id); err != nil {
+		return completedAt, err
+	}
+
+	err := s.db.QueryRowContext(ctx, `SELECT completed_at FROM data_exports WHERE id = ?`, id).Scan(&completedAt)
+	return completedAt, err
+}
+
+func (s *ExportStore) MarkFailed(ctx context.Context, id int64, reason string) error {
+	query := `UPDATE data_exports SET status = ?, error = ?, completed_at = NOW() WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, ExportStatusFailed, reason, id)
+	return err
+}
+
+// CollectUserData loads everything stored about a user.
+func (s *ExportStore) CollectUserData(ctx context.Context, userID int64) (*UserDataExport, error) {
+	data := &UserDataExport{}
+
+	if err := s.collectProfile(ctx, userID, &data.Profile); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if data.Posts, err = s.collectPosts(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.Comments, err = s.collectComments(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	followersQuery := `
+		SELECT u.id, u.username, f.created_at
+		FROM followers f
+		JOIN users u ON u.id = f.follower_id
+		WHERE f.user_id = ?
+		ORDER BY f.created_at`
+	if data.Followers, err = s.collectFollows(ctx, followersQuery, userID); err != nil {
+		return nil, err
+	}
+
+	followingQuery := `
+		SELECT u.id, u.username, f.created_at
+		FROM followers f
+		JOIN users u ON u.id = f.user_id
+		WHERE f.follower_id = ?
+		ORDER BY f.created_at`
+	if data.Following, err = s.collectFollows(ctx, followingQuery, userID); err != nil {
+		return nil, err
+	}
+
+	if data.Invitations, err = s.collectInvitations(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *ExportStore) collectProfile(ctx context.Context, userID int64, profile *ExportProfile) error {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.email, u.is_active, u.is_private, r.name, u.created_at
+		FROM users u
+		JOIN roles r ON r.id = u.role_id
+		WHERE u.id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, userID).Scan(
+		&profile.ID,
+		&profile.Username,
+		&profile.DisplayName,
+		&profile.Email,
+		&profile.IsActive,
+		&profile.IsPrivate,
+		&profile.Role,
+		&profile.CreatedAt,
+	)
+}
+
+func (s *ExportStore) collectPosts(ctx context.Context, userID int64) ([]ExportPost, error) {
+	query := `
+		SELECT p.id, p.title, p.content, ` + postTagsJSON + `, p.version, p.created_at, p.updated_at
+		FROM posts p
+		WHERE p.user_id = ?
+		ORDER BY p.created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []ExportPost{}
+	for rows.Next() {
+		var p ExportPost
+		var tagsSQL sql.NullString
+		if err := rows.Scan(&p.ID, &p.Title, &p.Content, &tagsSQL, &p.Version, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		p.Tags = []string{}
+		if tagsSQL.Valid && tagsSQL.String != "" {
+			if err := json.Unmarshal([]byte(tagsSQL.String), &p.Tags); err != nil {
+				return nil, err
+			}
+		}
+		p.Revisions = []PostRevision{}
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	revisionsQuery := `
+		SELECT r.post_id, r.version, r.title, r.content, r.tags, r.created_at
+		FROM post_revisions r
+		JOIN posts p ON p.id = r.post_id
+		WHERE p.user_id = ?
+		ORDER BY r.post_id, r.version`
+
+	revRows, err := s.db.QueryContext(ctx, revisionsQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer revRows.Close()
+
+	byPost := map[int64][]PostRevision{}
+	for revRows.Next() {
+		var rev PostRevision
+		if err := scanRevision(revRows, &rev); err != nil {
+			return nil, err
+		}
+		byPost[rev.PostID] = append(byPost[rev.PostID], rev)
+	}
+
+	for i := range posts {
+		if revisions, ok := byPost[posts[i].ID]; ok {
+			posts[i].Revisions = revisions
+		}
+	}
+
+	return posts, revRows.Err()
+}
+
+func (s *ExportStore) collectComments(ctx context.Context, userID int64) ([]ExportComment, error) {
+	query := `SELECT id, post_id, content, created_at FROM comments WHERE user_id = ? AND is_deleted = FALSE ORDER BY created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []ExportComment{}
+	for rows.Next() {
+		var c ExportComment
+		if err := rows.Scan(&c.ID, &c.PostID, &c.Content, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	return comments, rows.Err()
+}
+
+func (s *ExportStore) collectFollows(ctx context.Context, query string, userID int64) ([]ExportFollow, error) {
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	follows := []ExportFollow{}
+	for rows.Next() {
+		var f ExportFollow
+		if err := rows.Scan(&f.UserID, &f.Username, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		follows = append(follows, f)
+	}
+
+	return follows, rows.Err()
+}
+
+func (s *ExportStore) collectInvitations(ctx context.Context, userID int64) ([]ExportInvitation, error) {
+	// token chỉ lưu dạng hash nên không đưa vào file export
+	query := `SELECT expiry FROM user_invitations WHERE user_id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []ExportInvitation{}
+	for rows.Next() {
+		var inv ExportInvitation
+		if err := rows.Scan(&inv.Expiry); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, inv)
+	}
+
+	return invitations, rows.Err()
+}
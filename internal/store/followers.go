@@ -3,17 +3,29 @@ package store
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"errors"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 )
 
+// ErrAlreadyFollowing is returned when following, or asking to follow, a user
+// that is already followed.
+var ErrAlreadyFollowing = errors.New("you are already following this user")
+
 type Follower struct {
 	UserID     int64 `json:"user_id"`
 	FollowerID int64 `json:"follower_id"`
 	CreatedAt  int64 `json:"created_at"`
 }
 
+type FollowRequest struct {
+	UserID     int64     `json:"user_id"`
+	FollowerID int64     `json:"follower_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Follower   User      `json:"follower"`
+}
+
 type FollowerStore struct {
 	db *sql.DB
 }
@@ -29,7 +41,7 @@ func (s *FollowerStore) Follow(ctx context.Context, followerID, UserID int64) er
 
 	if err != nil {
 		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
-			return fmt.Errorf("you are already following this user")
+			return ErrAlreadyFollowing
 		}
 	}
 
@@ -37,12 +49,162 @@ func (s *FollowerStore) Follow(ctx context.Context, followerID, UserID int64) er
 }
 
 func (s *FollowerStore) UnFollow(ctx context.Context, followerID, UserID int64) error {
-	query := `
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
 		DELETE FROM followers 
 		WHERE user_id = ? AND follower_id = ?`
 
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+		if _, err := tx.ExecContext(ctx, query, UserID, followerID); err != nil {
+			return err
+		}
+
+		// unfollow cũng huỷ luôn follow request đang chờ duyệt
+		if err := deleteFollowRequest(ctx, tx, UserID, followerID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *FollowerStore) IsFollowing(ctx context.Context, followerID, userID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = ? AND follower_id = ?)`
+
 	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
 	defer cancel()
-	_, err := s.db.ExecContext(ctx, query, UserID, followerID)
+
+	var following bool
+	err := s.db.QueryRowContext(ctx, query, userID, followerID).Scan(&following)
+	return following, err
+}
+
+// RequestFollow records a pending follow request to a private account.
+func (s *FollowerStore) RequestFollow(ctx context.Context, followerID, userID int64) error {
+	following, err := s.IsFollowing(ctx, followerID, userID)
+	if err != nil {
+		return err
+	}
+	if following {
+		return ErrAlreadyFollowing
+	}
+
+	query := `
+		INSERT INTO follow_requests (user_id, follower_id, created_at)
+		VALUES (?, ?, NOW())`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	_, err = s.db.ExecContext(ctx, query, userID, followerID)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return ErrConflict
+		}
+	}
+
 	return err
 }
+
+// GetFollowRequests returns the pending follow requests of a user, oldest first.
+func (s *FollowerStore) GetFollowRequests(ctx context.Context, userID int64) ([]FollowRequest, error) {
+	query := `
+		SELECT fr.user_id, fr.follower_id, fr.created_at, u.id, u.username
+		FROM follow_requests fr
+		JOIN users u ON u.id = fr.follower_id
+		WHERE fr.user_id = ?
+		ORDER BY fr.created_at ASC`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []FollowRequest{}
+	for rows.Next() {
+		var fr FollowRequest
+		if err := rows.Scan(&fr.UserID, &fr.FollowerID, &fr.CreatedAt, &fr.Follower.ID, &fr.Follower.Username); err != nil {
+			return nil, err
+		}
+		requests = append(requests, fr)
+	}
+
+	return requests, rows.Err()
+}
+
+// ApproveFollowRequest turns a pending request into a follower relationship.
+func (s *FollowerStore) ApproveFollowRequest(ctx context.Context, userID, followerID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := deleteFollowRequest(ctx, tx, userID, followerID); err != nil {
+			return err
+		}
+
+		query := `
+		INSERT IGNORE INTO followers (user_id, follower_id, created_at)
+		VALUES (?, ?, NOW())`
+
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, query, userID, followerID)
+		return err
+	})
+}
+
+func (s *FollowerStore) RejectFollowRequest(ctx context.Context, userID, followerID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return deleteFollowRequest(ctx, tx, userID, followerID)
+	})
+}
+
+func deleteFollowRequest(ctx context.Context, tx *sql.Tx, userID, followerID int64) error {
+	query := `DELETE FROM follow_requests WHERE user_id = ? AND follower_id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, userID, followerID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetFollowerIDs returns the IDs of the users following a user.
+func (s *FollowerStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	query := `SELECT follower_id FROM followers WHERE user_id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
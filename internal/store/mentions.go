@@ -0,0 +1,259 @@
+package store
+
+import (
+	"backendwithgo/internal/mention"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+)
+
+// Mention is an @username in a post or comment that resolved to a user.
+// Offset and Length are counted in characters and include the "@".
+type Mention struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Offset   int    `json:"offset"`
+	Length   int    `json:"length"`
+}
+
+type MentionStore struct {
+	db *sql.DB
+}
+
+// Sync parses the mentions of a post (commentID nil) or of a comment of the
+// post, resolves them against active users and replaces the stored ones.
+// Unknown usernames are ignored. It also returns the users that were not
+// mentioned in the previous version of the content.
+func (s *MentionStore) Sync(ctx context.Context, postID int64, commentID *int64, content string) ([]Mention, []int64, error) {
+	candidates := mention.Parse(content)
+
+	usernames := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		usernames = append(usernames, c.Username)
+	}
+
+	users, err := s.resolve(ctx, usernames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mentions := []Mention{}
+	for _, c := range candidates {
+		u, ok := users[strings.ToLower(c.Username)]
+		if !ok {
+			continue
+		}
+		mentions = append(mentions, Mention{
+			UserID:   u.ID,
+			Username: u.Username,
+			Offset:   c.Offset,
+			Length:   c.Length,
+		})
+	}
+
+	added := []int64{}
+	err = withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		previous := map[int64]bool{}
+		rows, err := tx.QueryContext(ctx, `SELECT user_id FROM mentions WHERE post_id = ? AND comment_id <=> ?`, postID, commentID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			previous[id] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, m := range mentions {
+			if !previous[m.UserID] {
+				previous[m.UserID] = true
+				added = append(added, m.UserID)
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM mentions WHERE post_id = ? AND comment_id <=> ?`, postID, commentID)
+		if err != nil {
+			return err
+		}
+
+		if len(mentions) == 0 {
+			return nil
+		}
+
+		values := make([]string, 0, len(mentions))
+		args := make([]any, 0, len(mentions)*5)
+		for _, m := range mentions {
+			values = append(values, "(?, ?, ?, ?, ?)")
+			args = append(args, postID, commentID, m.UserID, m.Offset, m.Length)
+		}
+
+		query := `INSERT INTO mentions (post_id, comment_id, user_id, start_offset, length) VALUES ` + strings.Join(values, ", ")
+		_, err = tx.ExecContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return mentions, added, nil
+}
+
+func (s *MentionStore) resolve(ctx context.Context, usernames []string) (map[string]User, error) {
+	users := map[string]User{}
+	if len(usernames) == 0 {
+		return users, nil
+	}
+
+	query := `SELECT id, username FROM users WHERE is_active = TRUE AND username IN (` + placeholders(len(usernames)) + `)`
+
+	args := make([]any, 0, len(usernames))
+	for _, u := range usernames {
+		args = append(args, u)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username); err != nil {
+			return nil, err
+		}
+		users[strings.ToLower(u.Username)] = u
+	}
+
+	return users, rows.Err()
+}
+
+// GetForPosts returns the mentions in the content of the given posts, keyed
+// by post ID.
+func (s *MentionStore) GetForPosts(ctx context.Context, postIDs []int64) (map[int64][]Mention, error) {
+	query := `
+		SELECT m.post_id, m.user_id, u.username, m.start_offset, m.length
+		FROM mentions m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.comment_id IS NULL AND m.post_id IN (` + placeholders(len(postIDs)) + `)
+		ORDER BY m.start_offset`
+
+	return s.getFor(ctx, query, postIDs)
+}
+
+// GetForComments returns the mentions in the content of the given comments,
+// keyed by comment ID.
+func (s *MentionStore) GetForComments(ctx context.Context, commentIDs []int64) (map[int64][]Mention, error) {
+	query := `
+		SELECT m.comment_id, m.user_id, u.username, m.start_offset, m.length
+		FROM mentions m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.comment_id IN (` + placeholders(len(commentIDs)) + `)
+		ORDER BY m.start_offset`
+
+	return s.getFor(ctx, query, commentIDs)
+}
+
+func (s *MentionStore) getFor(ctx context.Context, query string, ids []int64) (map[int64][]Mention, error) {
+	mentions := map[int64][]Mention{}
+	if len(ids) == 0 {
+		return mentions, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, int64Args(ids)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var m Mention
+		if err := rows.Scan(&id, &m.UserID, &m.Username, &m.Offset, &m.Length); err != nil {
+			return nil, err
+		}
+		mentions[id] = append(mentions[id], m)
+	}
+
+	return mentions, rows.Err()
+}
+
+// GetPostsMentioning lists the posts whose content mentions the user, newest
+// first, skipping posts the user is not allowed to see.
+func (s *MentionStore) GetPostsMentioning(ctx context.Context, userID int64, fq PaginationQuery) ([]PostWithData, error) {
+	query := `
+		SELECT DISTINCT
+			p.id,
+			p.user_id,
+			p.title,
+			p.content,
+			p.created_at,
+			p.version,
+			` + postTagsJSON + `,
+			u.username
+		FROM mentions m
+		JOIN posts p ON p.id = m.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE m.user_id = ? AND m.comment_id IS NULL AND p.status = 'published'
+			AND` + visibleToViewer + `
+		ORDER BY p.created_at DESC
+		LIMIT ? OFFSET ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, userID, userID, userID, userID, userID, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithData{}
+	for rows.Next() {
+		var post PostWithData
+		var tagsSQL sql.NullString
+
+		err := rows.Scan(
+			&post.ID,
+			&post.UserID,
+			&post.Title,
+			&post.Content,
+			&post.CreatedAt,
+			&post.Version,
+			&tagsSQL,
+			&post.User.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		post.Tags = []string{}
+		if tagsSQL.Valid && tagsSQL.String != "" {
+			if err := json.Unmarshal([]byte(tagsSQL.String), &post.Tags); err != nil {
+				return nil, err
+			}
+		}
+
+		post.Comments = []Comment{}
+		posts = append(posts, post)
+	}
+
+	return posts, rows.Err()
+}
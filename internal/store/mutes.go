@@ -0,0 +1,181 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+const (
+	MuteKindUser    = "user"
+	MuteKindTag     = "tag"
+	MuteKindKeyword = "keyword"
+)
+
+type Mute struct {
+	ID           int64      `json:"id"`
+	UserID       int64      `json:"user_id"`
+	Kind         string     `json:"kind"`
+	TargetUserID int64      `json:"target_user_id,omitempty"`
+	Value        string     `json:"value,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type MuteStore struct {
+	db *sql.DB
+}
+
+// Create stores a mute rule. Muting the same user, tag or keyword again only
+// refreshes the expiry of the existing rule.
+func (s *MuteStore) Create(ctx context.Context, mute *Mute) error {
+	query := `
+		INSERT INTO user_mutes (user_id, kind, target_user_id, value, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id), expires_at = VALUES(expires_at)`
+
+	// tag và keyword so khớp không phân biệt hoa thường
+	mute.Value = strings.ToLower(strings.TrimSpace(mute.Value))
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, mute.UserID, mute.Kind, mute.TargetUserID, mute.Value, mute.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	mute.ID = id
+
+	return s.db.QueryRowContext(ctx, `SELECT created_at FROM user_mutes WHERE id = ?`, mute.ID).Scan(&mute.CreatedAt)
+}
+
+// GetByUserID returns the active (not expired) mute rules of a user.
+func (s *MuteStore) GetByUserID(ctx context.Context, userID int64) ([]Mute, error) {
+	query := `
+		SELECT id, user_id, kind, target_user_id, value, expires_at, created_at
+		FROM user_mutes
+		WHERE user_id = ? AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mutes := []Mute{}
+	for rows.Next() {
+		var m Mute
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&m.ID, &m.UserID, &m.Kind, &m.TargetUserID, &m.Value, &expiresAt, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			m.ExpiresAt = &expiresAt.Time
+		}
+		mutes = append(mutes, m)
+	}
+
+	return mutes, rows.Err()
+}
+
+func (s *MuteStore) Delete(ctx context.Context, userID, muteID int64) error {
+	query := `DELETE FROM user_mutes WHERE id = ? AND user_id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, muteID, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// muteMatches holds for the mute rules aliased as m that are active and match
+// the post aliased as p.
+const muteMatches = `
+	(m.expires_at IS NULL OR m.expires_at > NOW())
+	AND (
+		(m.kind = 'user' AND m.target_user_id = p.user_id)
+		OR (m.kind = 'tag' AND EXISTS (
+			SELECT 1 FROM post_tags mpt JOIN tags mt ON mt.id = mpt.tag_id
+			WHERE mpt.post_id = p.id AND mt.name = m.value
+		))
+		OR (m.kind = 'keyword' AND (LOCATE(m.value, LOWER(p.title)) > 0 OR LOCATE(m.value, LOWER(p.content)) > 0))
+	)`
+
+// muteFilter excludes posts matched by any active mute rule of the viewer.
+// It expects the posts table aliased as p and takes the viewer ID as its
+// only argument.
+const muteFilter = `
+	NOT EXISTS (
+		SELECT 1 FROM user_mutes m
+		WHERE m.user_id = ? AND` + muteMatches + `
+	)`
+
+// FilterMuting returns the users of userIDs that have no active mute rule
+// matching the post, in the same order.
+func (s *MuteStore) FilterMuting(ctx context.Context, postID int64, userIDs []int64) ([]int64, error) {
+	const batchSize = 500
+
+	muting := map[int64]bool{}
+	for start := 0; start < len(userIDs); start += batchSize {
+		batch := userIDs[start:min(start+batchSize, len(userIDs))]
+
+		query := `
+			SELECT DISTINCT m.user_id
+			FROM user_mutes m
+			JOIN posts p ON p.id = ?
+			WHERE m.user_id IN (` + placeholders(len(batch)) + `) AND` + muteMatches
+
+		if err := s.collectMuting(ctx, query, append([]any{postID}, int64Args(batch)...), muting); err != nil {
+			return nil, err
+		}
+	}
+
+	unmuted := make([]int64, 0, len(userIDs))
+	for _, id := range userIDs {
+		if !muting[id] {
+			unmuted = append(unmuted, id)
+		}
+	}
+	return unmuted, nil
+}
+
+func (s *MuteStore) collectMuting(ctx context.Context, query string, args []any, muting map[int64]bool) error {
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		muting[id] = true
+	}
+
+	return rows.Err()
+}
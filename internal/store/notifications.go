@@ -0,0 +1,372 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	NotificationFollow        = "follow"
+	NotificationFollowRequest = "follow_request"
+	NotificationComment       = "comment"
+	NotificationMention       = "mention"
+)
+
+const (
+	TargetUser    = "user"
+	TargetPost    = "post"
+	TargetComment = "comment"
+)
+
+// Notification groups the events of one type on one target while it is
+// unread: "alice and 3 others commented on your post". Actor is the latest
+// actor and ActorCount the number of distinct actors in the group.
+type Notification struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	Type       string    `json:"type"`
+	TargetType string    `json:"target_type"`
+	TargetID   int64     `json:"target_id"`
+	ActorID    int64     `json:"-"`
+	Actor      User      `json:"actor"`
+	ActorCount int       `json:"actor_count"`
+	IsRead     bool      `json:"is_read"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type NotificationStore struct {
+	db *sql.DB
+}
+
+// Create records an event, merging it into the unread notification of the
+// same type and target if there is one.
+func (s *NotificationStore) Create(ctx context.Context, n *Notification) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		query := `
+		INSERT INTO notifications (user_id, type, target_type, target_id, actor_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, NOW(), NOW())
+		ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id), actor_id = VALUES(actor_id), updated_at = NOW()`
+
+		res, err := tx.ExecContext(ctx, query, n.UserID, n.Type, n.TargetType, n.TargetID, n.ActorID)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		n.ID = id
+
+		_, err = tx.ExecContext(ctx, `INSERT IGNORE INTO notification_actors (notification_id, actor_id) VALUES (?, ?)`, n.ID, n.ActorID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE notifications
+			SET actor_count = (SELECT COUNT(*) FROM notification_actors WHERE notification_id = ?)
+			WHERE id = ?`, n.ID, n.ID)
+		if err != nil {
+			return err
+		}
+
+		return tx.QueryRowContext(ctx, `
+			SELECT n.actor_count, n.created_at, n.updated_at, u.id, u.username
+			FROM notifications n
+			JOIN users u ON u.id = n.actor_id
+			WHERE n.id = ?`, n.ID).Scan(&n.ActorCount, &n.CreatedAt, &n.UpdatedAt, &n.Actor.ID, &n.Actor.Username)
+	})
+}
+
+// GetByUserID lists the notifications of a user, most recently updated first.
+func (s *NotificationStore) GetByUserID(ctx context.Context, userID int64, unreadOnly bool, fq PaginationQuery) ([]Notification, error) {
+	query := `
+		SELECT n.id, n.user_id, n.type, n.target_type, n.target_id, n.actor_count, n.is_read,
+			n.created_at, n.updated_at, u.id, u.username
+		FROM notifications n
+		JOIN users u ON u.id = n.actor_id
+		WHERE n.user_id = ? AND (? = FALSE OR n.is_read = FALSE)
+		ORDER BY n.updated_at DESC, n.id DESC
+		LIMIT ? OFFSET ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, unreadOnly, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		err := rows.Scan(
+			&n.ID,
+			&n.UserID,
+			&n.Type,
+			&n.TargetType,
+			&n.TargetID,
+			&n.ActorCount,
+			&n.IsRead,
+			&n.CreatedAt,
+			&n.UpdatedAt,
+			&n.Actor.ID,
+			&n.Actor.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+		n.ActorID = n.Actor.ID
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+func (s *NotificationStore) UnreadCount(ctx context.Context, userID int64) (int, error) {
+	query := `SELECT COUNT(*) FROM notifications WHERE user_id = ? AND is_read = FALSE`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+// QueueEmail queues a notification for the email job. A notification already
+// queued keeps its place.
+func (s *NotificationStore) QueueEmail(ctx context.Context, id int64) error {
+	query := `UPDATE notifications SET email_queued_at = COALESCE(email_queued_at, NOW()) WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// ClaimEmails takes the notifications queued for email of the user waiting
+// the longest and removes them from the queue, or returns sql.ErrNoRows when
+// the queue is empty. SKIP LOCKED lets several API instances send emails at
+// the same time without emailing a notification twice.
+func (s *NotificationStore) ClaimEmails(ctx context.Context) (int64, []Notification, error) {
+	var userID int64
+	notifications := []Notification{}
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, `
+			SELECT user_id FROM notifications
+			WHERE email_queued_at IS NOT NULL
+			ORDER BY email_queued_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED`).Scan(&userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, `
+			SELECT n.id, n.user_id, n.type, n.target_type, n.target_id, n.actor_count, n.is_read,
+				n.created_at, n.updated_at, u.id, u.username
+			FROM notifications n
+			JOIN users u ON u.id = n.actor_id
+			WHERE n.user_id = ? AND n.email_queued_at IS NOT NULL
+			ORDER BY n.updated_at, n.id
+			FOR UPDATE OF n SKIP LOCKED`, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var n Notification
+			err := rows.Scan(
+				&n.ID,
+				&n.UserID,
+				&n.Type,
+				&n.TargetType,
+				&n.TargetID,
+				&n.ActorCount,
+				&n.IsRead,
+				&n.CreatedAt,
+				&n.UpdatedAt,
+				&n.Actor.ID,
+				&n.Actor.Username,
+			)
+			if err != nil {
+				return err
+			}
+			n.ActorID = n.Actor.ID
+			notifications = append(notifications, n)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		ids := make([]int64, len(notifications))
+		for i, n := range notifications {
+			ids[i] = n.ID
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE notifications SET email_queued_at = NULL, email_sent_at = NOW()
+			WHERE id IN (`+placeholders(len(ids))+`)`, int64Args(ids)...)
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return userID, notifications, nil
+}
+
+// MarkRead also takes the notification off the email queue.
+func (s *NotificationStore) MarkRead(ctx context.Context, userID, notificationID int64) error {
+	query := `UPDATE notifications SET is_read = TRUE, group_key = NULL, email_queued_at = NULL WHERE id = ? AND user_id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, notificationID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		// không có dòng nào thay đổi: hoặc không tồn tại hoặc đã đọc rồi
+		var exists bool
+		err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM notifications WHERE id = ? AND user_id = ?)`, notificationID, userID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+	}
+
+	return nil
+}
+
+func (s *NotificationStore) MarkAllRead(ctx context.Context, userID int64) error {
+	query := `UPDATE notifications SET is_read = TRUE, group_key = NULL, email_queued_at = NULL WHERE user_id = ? AND is_read = FALSE`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+const (
+	ChannelInApp = "in_app"
+	ChannelEmail = "email"
+	ChannelOff   = "off"
+)
+
+// NotificationTypes lists the notification types users can set a
+// preference for.
+var NotificationTypes = []string{
+	NotificationFollow,
+	NotificationFollowRequest,
+	NotificationComment,
+	NotificationMention,
+}
+
+// NotificationPreferences maps a notification type to its delivery channel:
+// in_app stores and streams the notification, email also emails it, and off
+// drops it. Types without a stored preference default to in_app.
+type NotificationPreferences map[string]string
+
+type NotificationPreferenceStore struct {
+	db *sql.DB
+}
+
+func (s *NotificationPreferenceStore) Get(ctx context.Context, userID int64) (NotificationPreferences, error) {
+	query := `SELECT type, channel FROM notification_preferences WHERE user_id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefs := NotificationPreferences{}
+	for _, t := range NotificationTypes {
+		prefs[t] = ChannelInApp
+	}
+
+	for rows.Next() {
+		var t, channel string
+		if err := rows.Scan(&t, &channel); err != nil {
+			return nil, err
+		}
+		prefs[t] = channel
+	}
+
+	return prefs, rows.Err()
+}
+
+func (s *NotificationPreferenceStore) GetChannel(ctx context.Context, userID int64, notificationType string) (string, error) {
+	query := `SELECT channel FROM notification_preferences WHERE user_id = ? AND type = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	var channel string
+	err := s.db.QueryRowContext(ctx, query, userID, notificationType).Scan(&channel)
+	if err == sql.ErrNoRows {
+		return ChannelInApp, nil
+	}
+
+	return channel, err
+}
+
+func (s *NotificationPreferenceStore) Set(ctx context.Context, userID int64, prefs NotificationPreferences) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		INSERT INTO notification_preferences (user_id, type, channel) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE channel = VALUES(channel)`
+
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		for t, channel := range prefs {
+			if _, err := tx.ExecContext(ctx, query, userID, t, channel); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// UnsubscribeEmail moves every notification type delivered by email back to
+// in-app only.
+func (s *NotificationPreferenceStore) UnsubscribeEmail(ctx context.Context, userID int64) error {
+	query := `UPDATE notification_preferences SET channel = ? WHERE user_id = ? AND channel = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, ChannelInApp, userID, ChannelEmail)
+	return err
+}
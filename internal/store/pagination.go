@@ -15,6 +15,9 @@ type PaginationQuery struct {
 	Search string   `json:"search" validate:"max=100"`
 	Since  string   `json:"since" `
 	Until  string   `json:"until"`
+	// Cursor is the next_cursor of the previous page, for the listings
+	// paginated by keyset rather than Offset.
+	Cursor string `json:"cursor"`
 }
 
 func (fq *PaginationQuery) Parse(r *http.Request) (PaginationQuery, error) {
@@ -52,6 +55,8 @@ func (fq *PaginationQuery) Parse(r *http.Request) (PaginationQuery, error) {
 		fq.Search = search
 	}
 
+	fq.Cursor = qs.Get("cursor")
+
 	since := qs.Get("since")
 	if since != "" {
 
@@ -5,9 +5,24 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
-	"strings"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
+const (
+	PostVisibilityPublic    = "public"
+	PostVisibilityFollowers = "followers"
+	PostVisibilityMentioned = "mentioned"
+	PostVisibilityPrivate   = "private"
 )
 
 type Post struct {
@@ -21,55 +36,194 @@ type Post struct {
 	Version   int       `json:"version"`
 	Comments  []Comment `json:"comments"`
 	User      User      `json:"user"`
+	Mentions  []Mention `json:"mentions"`
+	// CommentsTotal and CommentsCursor describe the comments when only the
+	// first page of them is embedded in Comments.
+	CommentsTotal  int     `json:"comments_total,omitempty"`
+	CommentsCursor *string `json:"comments_cursor,omitempty"`
+	// QuotedPostID is set on quote posts; QuotedPost is the quoted post when
+	// the viewer may see it.
+	QuotedPostID *int64 `json:"quoted_post_id"`
+	QuotedPost   *Post  `json:"quoted_post,omitempty"`
+	// Status is draft, scheduled or published. Scheduled posts are published
+	// at PublishAt by the background publisher.
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at"`
+	PublishedAt *time.Time `json:"published_at"`
+	// Visibility is public, followers (approved followers only), mentioned
+	// (users mentioned in the post only) or private (the author only).
+	Visibility string `json:"visibility"`
+	// DeletedAt is set while the post is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// ContentHTML is Content, which is Markdown, rendered to sanitized HTML.
+	// It is filled in by the API and never stored.
+	ContentHTML string `json:"content_html"`
+	// Attachments are the uploaded media of the post, in order. On Create
+	// only their IDs are read.
+	Attachments []Attachment `json:"attachments"`
 }
 
 type PostWithData struct {
 	Post
-	CommentCount int `json:"commentcount"`
+	CommentCount int       `json:"commentcount"`
+	Reactions    Reactions `json:"reactions"`
+	RepostCount  int       `json:"repostcount"`
+	QuoteCount   int       `json:"quotecount"`
+	// RepostedBy and RepostedAt attribute a feed entry that is there because
+	// someone the viewer follows reposted it.
+	RepostedBy *User      `json:"reposted_by,omitempty"`
+	RepostedAt *time.Time `json:"reposted_at,omitempty"`
+	// FeedReason says why a post is in the feed: own, following, repost or
+	// tag. FeedTag is the followed tag for tag.
+	FeedReason string `json:"feed_reason,omitempty"`
+	FeedTag    string `json:"feed_tag,omitempty"`
 }
 
 type Poststore struct {
 	db *sql.DB
 }
 
-func (s *Poststore) GetUserFeed(ctx context.Context, userID int64, fq PaginationQuery) ([]PostWithData, error) {
-	conditions := []string{}
-	args := []any{userID, userID, fq.Search, fq.Search}
+// FeedPage is a page of the feed, most recent activity first.
+type FeedPage struct {
+	Posts      []PostWithData `json:"posts"`
+	NextCursor *string        `json:"next_cursor"`
+}
 
-	if len(fq.Tags) > 0 {
-		for _, tag := range fq.Tags {
-			conditions = append(conditions, "JSON_CONTAINS(p.tags, JSON_QUOTE(?))")
-			args = append(args, tag)
+// GetUserFeed lists the posts of the user and of the accounts they follow,
+// the posts those accounts reposted and the posts with tags the user follows,
+// by time of activity. A post shows up once, for the first reason that
+// applies in that order, which FeedReason tells.
+//
+// Each reason is a branch of the query that leaves out the posts an earlier
+// branch already takes, so every post has a single position: its publication,
+// or its latest repost. Each branch then reads at most a page past the cursor.
+func (s *Poststore) GetUserFeed(ctx context.Context, userID int64, fq PaginationQuery) (*FeedPage, error) {
+	var cursor []int64
+	if fq.Cursor != "" {
+		var err error
+		if cursor, err = decodeCursor(fq.Cursor, 2); err != nil {
+			return nil, err
 		}
 	}
 
+	// keyset giữ các dòng đứng sau cursor theo (activity, post id)
+	keyset := func(activity string, args *[]any) string {
+		if cursor == nil {
+			return "TRUE"
+		}
+		*args = append(*args, cursor[0], cursor[0], cursor[1])
+		return "(" + activity + " < FROM_UNIXTIME(?) OR (" + activity + " = FROM_UNIXTIME(?) AND p.id < ?))"
+	}
+
+	// postFilter lọc theo bài viết, áp dụng trong từng nhánh trước LIMIT
+	postFilter := func(args *[]any) string {
+		filter := `p.status = 'published'
+			AND (LOWER(p.title) LIKE CONCAT('%', LOWER(?), '%')
+				OR LOWER(p.content) LIKE CONCAT('%', LOWER(?), '%'))
+			AND` + muteFilter + `
+			AND` + visibleToViewer
+		*args = append(*args, fq.Search, fq.Search, userID, userID, userID, userID, userID, userID)
+
+		if len(fq.Tags) > 0 {
+			filter += ` AND EXISTS (
+				SELECT 1 FROM post_tags ft JOIN tags ftg ON ftg.id = ft.tag_id
+				WHERE ft.post_id = p.id AND ftg.name IN (` + placeholders(len(fq.Tags)) + `))`
+			for _, tag := range fq.Tags {
+				*args = append(*args, tag)
+			}
+		}
+		return filter
+	}
+
+	const followsAuthor = `EXISTS (SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = ?)`
+	args := []any{}
+
+	ownOrFollowing := `
+		(SELECT p.id AS post_id, p.published_at AS activity_at,
+			IF(p.user_id = ?, 'own', 'following') AS reason, CAST(NULL AS CHAR) AS reason_tag
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE (p.user_id = ? OR ` + followsAuthor + `)`
+	args = append(args, userID, userID, userID)
+	ownOrFollowing += `
+			AND ` + keyset("p.published_at", &args) + `
+			AND ` + postFilter(&args) + `
+		ORDER BY p.published_at DESC, p.id DESC
+		LIMIT ?)`
+	args = append(args, fq.Limit+1)
+
+	// bài được repost xếp theo lần repost mới nhất; bài đã có lần repost
+	// trước cursor thì đã hiện ở trang trước
+	const byFollowed = `(%[1]s.user_id = ? OR EXISTS (SELECT 1 FROM followers f WHERE f.user_id = %[1]s.user_id AND f.follower_id = ?))`
+	reposted := `
+		(SELECT p.id, MAX(r.created_at), 'repost', NULL
+		FROM reposts r
+		JOIN posts p ON p.id = r.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE ` + fmt.Sprintf(byFollowed, "r") + `
+			AND p.user_id <> ? AND NOT ` + followsAuthor
+	args = append(args, userID, userID, userID, userID)
+	reposted += `
+			AND ` + keyset("r.created_at", &args)
+	if cursor != nil {
+		reposted += `
+			AND NOT EXISTS (
+				SELECT 1 FROM reposts nr
+				WHERE nr.post_id = p.id AND ` + fmt.Sprintf(byFollowed, "nr") + `
+					AND (nr.created_at > FROM_UNIXTIME(?) OR (nr.created_at = FROM_UNIXTIME(?) AND p.id >= ?)))`
+		args = append(args, userID, userID, cursor[0], cursor[0], cursor[1])
+	}
+	reposted += `
+			AND ` + postFilter(&args) + `
+		GROUP BY p.id
+		ORDER BY MAX(r.created_at) DESC, p.id DESC
+		LIMIT ?)`
+	args = append(args, fq.Limit+1)
+
+	tagged := `
+		(SELECT p.id, p.published_at, 'tag', MIN(t.name)
+		FROM tag_follows tf
+		JOIN post_tags pt ON pt.tag_id = tf.tag_id
+		JOIN tags t ON t.id = tf.tag_id
+		JOIN posts p ON p.id = pt.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE tf.user_id = ?
+			AND p.user_id <> ? AND NOT ` + followsAuthor + `
+			AND NOT EXISTS (SELECT 1 FROM reposts r WHERE r.post_id = p.id AND ` + fmt.Sprintf(byFollowed, "r") + `)`
+	args = append(args, userID, userID, userID, userID, userID)
+	tagged += `
+			AND ` + keyset("p.published_at", &args) + `
+			AND ` + postFilter(&args) + `
+		GROUP BY p.id, p.published_at
+		ORDER BY p.published_at DESC, p.id DESC
+		LIMIT ?)`
+	args = append(args, fq.Limit+1)
+
 	query := `
-	SELECT DISTINCT
-		p.id,
-		p.user_id,
-		p.title,
-		p.content,
-		p.created_at,
-		p.version,
-		p.tags,
-		u.username
-	FROM posts p
-	LEFT JOIN users u ON p.user_id = u.id
-	JOIN followers f ON f.user_id = p.user_id
-	WHERE 
-		(f.follower_id = ? OR p.user_id = ?)
-		AND (LOWER(p.title) LIKE CONCAT('%', LOWER(?), '%')
-			 OR LOWER(p.content) LIKE CONCAT('%', LOWER(?), '%'))`
-
-	if len(conditions) > 0 {
-		query += " AND (" + strings.Join(conditions, " OR ") + ")"
-	}
-
-	query += `
-	ORDER BY p.created_at DESC
-	LIMIT ? OFFSET ?;`
-
-	args = append(args, fq.Limit, fq.Offset)
+	SELECT p.id, p.user_id, p.title, p.content, p.created_at, p.version, ` + postTagsJSON + `, u.username,
+		(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.is_deleted = FALSE),
+		p.quoted_post_id, ru.id, ru.username, UNIX_TIMESTAMP(a.activity_at), a.activity_at, a.reason, a.reason_tag
+	FROM (
+		SELECT a.*, IF(a.reason = 'repost', (
+			SELECT r.user_id FROM reposts r
+			WHERE r.post_id = a.post_id AND r.created_at = a.activity_at AND ` + fmt.Sprintf(byFollowed, "r") + `
+			ORDER BY r.id DESC
+			LIMIT 1
+		), NULL) AS reposter_id
+		FROM (` + ownOrFollowing + `
+			UNION ALL` + reposted + `
+			UNION ALL` + tagged + `
+		) a
+		ORDER BY a.activity_at DESC, a.post_id DESC
+		LIMIT ?
+	) a
+	JOIN posts p ON p.id = a.post_id
+	JOIN users u ON u.id = p.user_id
+	LEFT JOIN users ru ON ru.id = a.reposter_id
+	ORDER BY a.activity_at DESC, p.id DESC`
+
+	args = append([]any{userID, userID}, args...)
+	args = append(args, fq.Limit+1)
 
 	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
 	defer cancel()
@@ -80,10 +234,15 @@ func (s *Poststore) GetUserFeed(ctx context.Context, userID int64, fq Pagination
 	}
 	defer rows.Close()
 
-	var feeds []PostWithData
+	page := &FeedPage{Posts: []PostWithData{}}
+	var lastID, lastActivity int64
 	for rows.Next() {
 		var post PostWithData
 		var tagsSQL sql.NullString
+		var quotedPostID, reposterID sql.NullInt64
+		var reposterUsername, reasonTag sql.NullString
+		var activity int64
+		var activityAt time.Time
 
 		err := rows.Scan(
 			&post.ID,
@@ -94,11 +253,25 @@ func (s *Poststore) GetUserFeed(ctx context.Context, userID int64, fq Pagination
 			&post.Version,
 			&tagsSQL,
 			&post.User.Username,
+			&post.CommentCount,
+			&quotedPostID,
+			&reposterID,
+			&reposterUsername,
+			&activity,
+			&activityAt,
+			&post.FeedReason,
+			&reasonTag,
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		if len(page.Posts) == fq.Limit {
+			cursor := encodeCursor(lastActivity, lastID)
+			page.NextCursor = &cursor
+			break
+		}
+
 		if tagsSQL.Valid && tagsSQL.String != "" {
 			if err := json.Unmarshal([]byte(tagsSQL.String), &post.Tags); err != nil {
 				return nil, err
@@ -107,54 +280,100 @@ func (s *Poststore) GetUserFeed(ctx context.Context, userID int64, fq Pagination
 			post.Tags = []string{}
 		}
 
+		if quotedPostID.Valid {
+			post.QuotedPostID = &quotedPostID.Int64
+		}
+		if reposterID.Valid {
+			post.RepostedBy = &User{ID: reposterID.Int64, Username: reposterUsername.String}
+			post.RepostedAt = &activityAt
+		}
+		if reasonTag.Valid {
+			post.FeedTag = reasonTag.String
+		}
+
+		post.User.ID = post.UserID
 		post.Comments = []Comment{}
-		feeds = append(feeds, post)
+		page.Posts = append(page.Posts, post)
+		lastID, lastActivity = post.ID, activity
 	}
 
-	return feeds, nil
+	return page, rows.Err()
 }
 
-func (s *Poststore) Create(ctx context.Context, post *Post) error {
-	// convert slice -> JSON để lưu vào MySQL JSON column
-	tagsJSON, err := json.Marshal(post.Tags)
-	if err != nil {
-		return err
-	}
+// visibleToViewer keeps the posts a viewer may read: their own posts, and
+// published posts whose visibility lets them in. Public posts are read by
+// everyone when the account is public and by followers otherwise, followers
+// posts by followers, and mentioned posts by the users they mention. Posts in
+// the trash are never visible, nor posts of a user blocking or blocked by the
+// viewer. It expects the post aliased as p and its author as u, and takes the
+// viewer ID five times.
+const visibleToViewer = `
+	(p.deleted_at IS NULL AND (p.user_id = ? OR (p.status = 'published' AND NOT EXISTS (
+		SELECT 1 FROM user_blocks vb
+		WHERE (vb.blocker_id = p.user_id AND vb.blocked_id = ?) OR (vb.blocker_id = ? AND vb.blocked_id = p.user_id)
+	) AND (
+		(p.visibility = 'mentioned' AND EXISTS (
+			SELECT 1 FROM mentions vm WHERE vm.post_id = p.id AND vm.comment_id IS NULL AND vm.user_id = ?
+		))
+		OR (p.visibility = 'public' AND u.is_private = FALSE)
+		OR (p.visibility IN ('public', 'followers') AND EXISTS (
+			SELECT 1 FROM followers vf WHERE vf.user_id = p.user_id AND vf.follower_id = ?
+		))
+	))))`
 
+func (s *Poststore) Create(ctx context.Context, post *Post) error {
 	query := `
-		INSERT INTO posts (content, title, user_id, tags, created_at, updated_at)
-		VALUES (?, ?, ?, ?, NOW(), NOW())`
+		INSERT INTO posts (content, title, user_id, quoted_post_id, status, publish_at, published_at, visibility, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, IF(? = 'published', NOW(), NULL), ?, NOW(), NOW())`
+
+	var publishedAt sql.NullTime
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		result, err := tx.ExecContext(
+			ctx,
+			query,
+			post.Content,
+			post.Title,
+			post.UserID,
+			post.QuotedPostID,
+			post.Status,
+			post.PublishAt,
+			post.Status,
+			post.Visibility,
+		)
+		if err != nil {
+			return err
+		}
 
-	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
-	defer cancel()
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		post.ID = id
 
-	result, err := s.db.ExecContext(
-		ctx,
-		query,
-		post.Content,
-		post.Title,
-		post.UserID,
-		tagsJSON,
-	)
-	if err != nil {
-		return err
-	}
+		if err := setPostTags(ctx, tx, post); err != nil {
+			return err
+		}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return err
-	}
-	post.ID = id
+		if err := attachToPost(ctx, tx, post); err != nil {
+			return err
+		}
 
-	// lấy created_at, updated_at từ DB để đồng bộ
-	err = s.db.QueryRowContext(
-		ctx,
-		`SELECT created_at, updated_at FROM posts WHERE id = ?`,
-		post.ID,
-	).Scan(&post.CreatedAt, &post.UpdatedAt)
+		// lấy created_at, updated_at từ DB để đồng bộ
+		return tx.QueryRowContext(
+			ctx,
+			`SELECT created_at, updated_at, published_at FROM posts WHERE id = ?`,
+			post.ID,
+		).Scan(&post.CreatedAt, &post.UpdatedAt, &publishedAt)
+	})
 	if err != nil {
 		return err
 	}
+	if publishedAt.Valid {
+		post.PublishedAt = &publishedAt.Time
+	}
 
 	// comments sẽ để trống, load ở hàm khác
 	post.Comments = []Comment{}
@@ -162,17 +381,58 @@ func (s *Poststore) Create(ctx context.Context, post *Post) error {
 	return nil
 }
 
-func (s *Poststore) GetByID(ctx context.Context, id int64) (*Post, error) {
-	query := `SELECT id, title, content, user_id, tags, created_at, updated_at, version
-              FROM posts
-              WHERE id = ?`
-	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
-	defer cancel()
+const postColumns = `p.id, p.title, p.content, p.user_id, ` + postTagsJSON + `, p.created_at, p.updated_at, p.version, p.quoted_post_id,
+	p.status, p.publish_at, p.published_at, p.visibility, p.deleted_at, u.id, u.username, u.is_private`
 
-	var p Post
+// postWithDataColumns are the columns read by scanPostWithData when listing
+// posts aliased as p with their author as u.
+const postWithDataColumns = `p.id, p.user_id, p.title, p.content, p.created_at, p.version, ` + postTagsJSON + `,
+	u.username, (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.is_deleted = FALSE), p.quoted_post_id`
+
+// scanPostWithData reads a row selected with postWithDataColumns followed by
+// the columns scanned into extra.
+func scanPostWithData(row rowScanner, post *PostWithData, extra ...any) error {
+	var tagsSQL sql.NullString
+	var quotedPostID sql.NullInt64
+
+	dest := []any{
+		&post.ID,
+		&post.UserID,
+		&post.Title,
+		&post.Content,
+		&post.CreatedAt,
+		&post.Version,
+		&tagsSQL,
+		&post.User.Username,
+		&post.CommentCount,
+		&quotedPostID,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	post.Tags = []string{}
+	if tagsSQL.Valid && tagsSQL.String != "" {
+		if err := json.Unmarshal([]byte(tagsSQL.String), &post.Tags); err != nil {
+			return err
+		}
+	}
+	if quotedPostID.Valid {
+		post.QuotedPostID = &quotedPostID.Int64
+	}
+
+	post.User.ID = post.UserID
+	post.Comments = []Comment{}
+	return nil
+}
+
+// scanPost reads a row selected with postColumns.
+func scanPost(row rowScanner, p *Post) error {
 	var tagsSQL sql.NullString
+	var quotedPostID sql.NullInt64
+	var publishAt, publishedAt, deletedAt sql.NullTime
 
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+	err := row.Scan(
 		&p.ID,
 		&p.Title,
 		&p.Content,
@@ -181,39 +441,114 @@ func (s *Poststore) GetByID(ctx context.Context, id int64) (*Post, error) {
 		&p.CreatedAt,
 		&p.UpdatedAt,
 		&p.Version,
+		&quotedPostID,
+		&p.Status,
+		&publishAt,
+		&publishedAt,
+		&p.Visibility,
+		&deletedAt,
+		&p.User.ID,
+		&p.User.Username,
+		&p.User.IsPrivate,
 	)
 	if err != nil {
-		log.Printf("DB scan error: %v", err)
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, sql.ErrNoRows
-		}
-		return nil, err
+		return err
 	}
 
 	// parse tags JSON thành []string
 	if tagsSQL.Valid && tagsSQL.String != "" {
 		if err := json.Unmarshal([]byte(tagsSQL.String), &p.Tags); err != nil {
-			return nil, err
+			return err
 		}
 	} else {
 		p.Tags = []string{}
 	}
 
+	if quotedPostID.Valid {
+		p.QuotedPostID = &quotedPostID.Int64
+	}
+	if publishAt.Valid {
+		p.PublishAt = &publishAt.Time
+	}
+	if publishedAt.Valid {
+		p.PublishedAt = &publishedAt.Time
+	}
+	if deletedAt.Valid {
+		p.DeletedAt = &deletedAt.Time
+	}
+
 	// comments để trống (load ở chỗ khác)
 	p.Comments = []Comment{}
+	return nil
+}
+
+func (s *Poststore) GetByID(ctx context.Context, id int64) (*Post, error) {
+	query := `SELECT ` + postColumns + `
+              FROM posts p
+              JOIN users u ON u.id = p.user_id
+              WHERE p.id = ? AND p.deleted_at IS NULL`
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	var p Post
+	err := scanPost(s.db.QueryRowContext(ctx, query, id), &p)
+	if err != nil {
+		log.Printf("DB scan error: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
 	return &p, nil
 }
 
-func (s *Poststore) Delete(ctx context.Context, postID int64) error {
-	query := `DELETE FROM posts WHERE id = ?`
+// GetDrafts lists the unpublished (draft and scheduled) posts of a user,
+// most recently edited first.
+func (s *Poststore) GetDrafts(ctx context.Context, userID int64, fq PaginationQuery) ([]Post, error) {
+	query := `SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.user_id = ? AND p.status <> 'published' AND p.deleted_at IS NULL
+		ORDER BY p.updated_at DESC, p.id DESC
+		LIMIT ? OFFSET ?`
 
 	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
 	defer cancel()
 
-	res, err := s.db.ExecContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		if err := scanPost(rows, &p); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+// Publish publishes a draft or scheduled post now. It returns sql.ErrNoRows
+// when the post is already published.
+func (s *Poststore) Publish(ctx context.Context, post *Post) error {
+	query := `
+		UPDATE posts SET status = 'published', publish_at = NULL, published_at = NOW()
+		WHERE id = ? AND status <> 'published'`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, post.ID)
 	if err != nil {
 		return err
 	}
+
 	rows, err := res.RowsAffected()
 	if err != nil {
 		return err
@@ -221,42 +556,306 @@ func (s *Poststore) Delete(ctx context.Context, postID int64) error {
 	if rows == 0 {
 		return sql.ErrNoRows
 	}
+
+	var publishedAt time.Time
+	if err := s.db.QueryRowContext(ctx, `SELECT published_at FROM posts WHERE id = ?`, post.ID).Scan(&publishedAt); err != nil {
+		return err
+	}
+
+	post.Status = PostStatusPublished
+	post.PublishAt = nil
+	post.PublishedAt = &publishedAt
 	return nil
+}
+
+// PublishDue publishes up to limit scheduled posts whose time has come and
+// returns them. The rows are claimed with SKIP LOCKED so that concurrent
+// publishers on other instances never publish the same post twice. Posts are
+// dated when they go out rather than at publish_at, so a late run does not
+// slip them behind the feed cursors clients already hold.
+func (s *Poststore) PublishDue(ctx context.Context, limit int) ([]Post, error) {
+	posts := []Post{}
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, `
+			SELECT id FROM posts
+			WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+			ORDER BY publish_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED`, limit)
+		if err != nil {
+			return err
+		}
+
+		ids := []int64{}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE posts SET status = 'published', published_at = NOW()
+			WHERE id IN (`+placeholders(len(ids))+`)`, int64Args(ids)...)
+		if err != nil {
+			return err
+		}
 
+		rows, err = tx.QueryContext(ctx, `SELECT `+postColumns+`
+			FROM posts p
+			JOIN users u ON u.id = p.user_id
+			WHERE p.id IN (`+placeholders(len(ids))+`)`, int64Args(ids)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var p Post
+			if err := scanPost(rows, &p); err != nil {
+				return err
+			}
+			posts = append(posts, p)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
 }
 
-func (s *Poststore) Update(ctx context.Context, post *Post) error {
-	tagsJSON, err := json.Marshal(post.Tags)
+// Delete moves a post to the trash. It stays there, hidden from every
+// listing, until it is restored or purged.
+func (s *Poststore) Delete(ctx context.Context, postID int64) error {
+	query := `UPDATE posts SET deleted_at = NOW() WHERE id = ? AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, postID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+
+}
+
+// GetDeletedByID fetches a post from the trash.
+func (s *Poststore) GetDeletedByID(ctx context.Context, id int64) (*Post, error) {
+	query := `SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id = ? AND p.deleted_at IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	var p Post
+	if err := scanPost(s.db.QueryRowContext(ctx, query, id), &p); err != nil {
+		return nil, err
+	}
 
-	// Bước 1: Update
-	updateQuery := `UPDATE posts
-	SET version = version + 1,
-		title = ?, content = ?, tags = ?, updated_at = NOW()
-	WHERE id = ? AND version = ?`
+	return &p, nil
+}
 
-	res, err := s.db.ExecContext(ctx, updateQuery, post.Title, post.Content, tagsJSON, post.ID, post.Version)
+// GetTrash lists the posts in the trash of a user, or of every user when
+// everyone is set, most recently deleted first.
+func (s *Poststore) GetTrash(ctx context.Context, userID int64, everyone bool, fq PaginationQuery) ([]Post, error) {
+	query := `SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.deleted_at IS NOT NULL AND (? OR p.user_id = ?)
+		ORDER BY p.deleted_at DESC, p.id DESC
+		LIMIT ? OFFSET ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, everyone, userID, fq.Limit, fq.Offset)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
-		return errors.New("no rows updated")
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		if err := scanPost(rows, &p); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
 	}
 
-	// Bước 2: Lấy version mới
-	query := `SELECT version FROM posts WHERE id = ?`
+	return posts, rows.Err()
+}
+
+// Restore takes a post out of the trash. It returns sql.ErrNoRows when the
+// post is not in the trash.
+func (s *Poststore) Restore(ctx context.Context, post *Post) error {
+	query := `UPDATE posts SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`
 
 	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
 	defer cancel()
 
-	row := s.db.QueryRowContext(ctx, query, post.ID)
-	if err := row.Scan(&post.Version); err != nil {
+	res, err := s.db.ExecContext(ctx, query, post.ID)
+	if err != nil {
 		return err
 	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
 
+	post.DeletedAt = nil
 	return nil
 }
+
+// PurgeDeleted permanently deletes up to limit posts that have been in the
+// trash for longer than retention, with their comments, and returns how many
+// posts were deleted. Rows locked by a purge running elsewhere are skipped.
+func (s *Poststore) PurgeDeleted(ctx context.Context, retention time.Duration, limit int) (int, error) {
+	var purged int
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, `
+			SELECT id FROM posts
+			WHERE deleted_at < NOW() - INTERVAL ? SECOND
+			ORDER BY deleted_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED`, int64(retention.Seconds()), limit)
+		if err != nil {
+			return err
+		}
+
+		ids := []int64{}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		// comments không có khóa ngoại tới posts nên phải xóa trước. Gỡ
+		// parent_id trước để DELETE không phải cascade qua fk_comments_parent:
+		// InnoDB từ chối cascade sâu quá 15 tầng
+		_, err = tx.ExecContext(ctx, `UPDATE comments SET parent_id = NULL WHERE post_id IN (`+placeholders(len(ids))+`) AND parent_id IS NOT NULL`, int64Args(ids)...)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM comments WHERE post_id IN (`+placeholders(len(ids))+`)`, int64Args(ids)...)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM posts WHERE id IN (`+placeholders(len(ids))+`)`, int64Args(ids)...)
+		if err != nil {
+			return err
+		}
+
+		purged = len(ids)
+		return nil
+	})
+
+	return purged, err
+}
+
+// Update saves a new version of the post, keeping the previous one as a
+// revision. It returns ErrEditConflict when post.Version is no longer the
+// current version.
+func (s *Poststore) Update(ctx context.Context, post *Post) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		// Bước 1: khoá bài viết ở đúng version client đã đọc, để hai lần sửa
+		// đồng thời không cùng ghi một revision
+		var locked int64
+		err := tx.QueryRowContext(ctx, `SELECT id FROM posts WHERE id = ? AND IFNULL(version, 0) = ? FOR UPDATE`,
+			post.ID, post.Version).Scan(&locked)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrEditConflict
+			}
+			return err
+		}
+
+		// Bước 2: lưu phiên bản hiện tại (đã khoá) vào lịch sử
+		revisionQuery := `
+		INSERT INTO post_revisions (post_id, version, title, content, tags, created_at)
+		SELECT p.id, IFNULL(p.version, 0), p.title, p.content, ` + postTagsJSON + `, p.updated_at
+		FROM posts p
+		WHERE p.id = ?`
+
+		if _, err := tx.ExecContext(ctx, revisionQuery, post.ID); err != nil {
+			if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+				return ErrEditConflict
+			}
+			return err
+		}
+
+		// Bước 3: Update
+		updateQuery := `UPDATE posts
+		SET version = IFNULL(version, 0) + 1,
+			title = ?, content = ?, visibility = ?, updated_at = NOW()
+		WHERE id = ? AND IFNULL(version, 0) = ?`
+
+		res, err := tx.ExecContext(ctx, updateQuery, post.Title, post.Content, post.Visibility, post.ID, post.Version)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, _ := res.RowsAffected()
+		if rowsAffected == 0 {
+			return ErrEditConflict
+		}
+
+		if err := setPostTags(ctx, tx, post); err != nil {
+			return err
+		}
+
+		// Bước 4: Lấy version mới
+		query := `SELECT version, updated_at FROM posts WHERE id = ?`
+
+		row := tx.QueryRowContext(ctx, query, post.ID)
+		return row.Scan(&post.Version, &post.UpdatedAt)
+	})
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Reactions summarises the reactions on a post or comment: how many of each
+// emoji, and which ones the viewer used.
+type Reactions struct {
+	Counts map[string]int `json:"counts"`
+	Viewer []string       `json:"viewer"`
+}
+
+func emptyReactions() Reactions {
+	return Reactions{Counts: map[string]int{}, Viewer: []string{}}
+}
+
+// Reactor is a user that reacted to a post.
+type Reactor struct {
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Emoji     string    `json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ReactionStore struct {
+	db *sql.DB
+}
+
+// Toggle adds the reaction of a user to a post (commentID nil) or to one of
+// its comments, or removes it if it is already there. It reports whether the
+// reaction was added. It returns ErrConflict when the same reaction was
+// added concurrently.
+func (s *ReactionStore) Toggle(ctx context.Context, userID, postID int64, commentID *int64, emoji string) (bool, error) {
+	added := false
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `
+			DELETE FROM reactions
+			WHERE user_id = ? AND post_id = ? AND comment_id <=> ? AND emoji = ?`,
+			userID, postID, commentID, emoji)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows > 0 {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO reactions (post_id, comment_id, user_id, emoji, created_at)
+			VALUES (?, ?, ?, ?, NOW())`,
+			postID, commentID, userID, emoji)
+		if err != nil {
+			if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+				return ErrConflict
+			}
+			return err
+		}
+
+		added = true
+		return nil
+	})
+
+	return added, err
+}
+
+// GetForPosts returns the reactions on the given posts as seen by the viewer,
+// keyed by post ID.
+func (s *ReactionStore) GetForPosts(ctx context.Context, viewerID int64, postIDs []int64) (map[int64]Reactions, error) {
+	query := `
+		SELECT post_id, emoji, COUNT(*), SUM(user_id = ?)
+		FROM reactions
+		WHERE comment_id IS NULL AND post_id IN (` + placeholders(len(postIDs)) + `)
+		GROUP BY post_id, emoji`
+
+	return s.getFor(ctx, query, viewerID, postIDs)
+}
+
+// GetForComments returns the reactions on the given comments as seen by the
+// viewer, keyed by comment ID.
+func (s *ReactionStore) GetForComments(ctx context.Context, viewerID int64, commentIDs []int64) (map[int64]Reactions, error) {
+	query := `
+		SELECT comment_id, emoji, COUNT(*), SUM(user_id = ?)
+		FROM reactions
+		WHERE comment_id IN (` + placeholders(len(commentIDs)) + `)
+		GROUP BY comment_id, emoji`
+
+	return s.getFor(ctx, query, viewerID, commentIDs)
+}
+
+func (s *ReactionStore) getFor(ctx context.Context, query string, viewerID int64, ids []int64) (map[int64]Reactions, error) {
+	reactions := map[int64]Reactions{}
+	for _, id := range ids {
+		reactions[id] = emptyReactions()
+	}
+	if len(ids) == 0 {
+		return reactions, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	args := append([]any{viewerID}, int64Args(ids)...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var emoji string
+		var count, mine int
+		if err := rows.Scan(&id, &emoji, &count, &mine); err != nil {
+			return nil, err
+		}
+
+		r := reactions[id]
+		r.Counts[emoji] = count
+		if mine > 0 {
+			r.Viewer = append(r.Viewer, emoji)
+		}
+		reactions[id] = r
+	}
+
+	return reactions, rows.Err()
+}
+
+// GetReactors lists the users that reacted to a post, most recent first,
+// optionally only those that used the given emoji.
+func (s *ReactionStore) GetReactors(ctx context.Context, postID int64, emoji string, fq PaginationQuery) ([]Reactor, error) {
+	query := `
+		SELECT u.id, u.username, r.emoji, r.created_at
+		FROM reactions r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.post_id = ? AND r.comment_id IS NULL AND (? = '' OR r.emoji = ?)
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT ? OFFSET ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID, emoji, emoji, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactors := []Reactor{}
+	for rows.Next() {
+		var r Reactor
+		if err := rows.Scan(&r.UserID, &r.Username, &r.Emoji, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		reactors = append(reactors, r)
+	}
+
+	return reactors, rows.Err()
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Repost is a boost of another user's post to the reposter's followers.
+type Repost struct {
+	UserID    int64     `json:"user_id"`
+	PostID    int64     `json:"post_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RepostCounts is how often a post was reposted and quoted.
+type RepostCounts struct {
+	Reposts int
+	Quotes  int
+}
+
+type RepostStore struct {
+	db *sql.DB
+}
+
+// Create returns ErrConflict when the user already reposted the post.
+func (s *RepostStore) Create(ctx context.Context, repost *Repost) error {
+	query := `INSERT INTO reposts (user_id, post_id, created_at) VALUES (?, ?, NOW())`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, repost.UserID, repost.PostID)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return ErrConflict
+		}
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	return s.db.QueryRowContext(ctx, `SELECT created_at FROM reposts WHERE id = ?`, id).Scan(&repost.CreatedAt)
+}
+
+func (s *RepostStore) Delete(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM reposts WHERE user_id = ? AND post_id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetCounts returns the repost and quote counts of the given posts, keyed by
+// post ID.
+func (s *RepostStore) GetCounts(ctx context.Context, postIDs []int64) (map[int64]RepostCounts, error) {
+	counts := map[int64]RepostCounts{}
+	if len(postIDs) == 0 {
+		return counts, nil
+	}
+
+	query := `
+		SELECT post_id, SUM(kind = 'repost'), SUM(kind = 'quote')
+		FROM (
+			SELECT post_id, 'repost' AS kind FROM reposts WHERE post_id IN (` + placeholders(len(postIDs)) + `)
+			UNION ALL
+			SELECT quoted_post_id, 'quote' FROM posts WHERE deleted_at IS NULL AND quoted_post_id IN (` + placeholders(len(postIDs)) + `)
+		) t
+		GROUP BY post_id`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	args := append(int64Args(postIDs), int64Args(postIDs)...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var c RepostCounts
+		if err := rows.Scan(&id, &c.Reposts, &c.Quotes); err != nil {
+			return nil, err
+		}
+		counts[id] = c
+	}
+
+	return counts, rows.Err()
+}
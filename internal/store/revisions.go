@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// PostRevision is the title, content and tags of a post at one version.
+type PostRevision struct {
+	PostID    int64     `json:"post_id"`
+	Version   int       `json:"version"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RevisionStore struct {
+	db *sql.DB
+}
+
+// GetByPostID lists the previous versions of a post, newest first. The
+// current version is the post itself.
+func (s *RevisionStore) GetByPostID(ctx context.Context, postID int64) ([]PostRevision, error) {
+	query := `
+		SELECT post_id, version, title, content, tags, created_at
+		FROM post_revisions
+		WHERE post_id = ?
+		ORDER BY version DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+	for rows.Next() {
+		var rev PostRevision
+		if err := scanRevision(rows, &rev); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+// Get returns a post at the given version, which may be a previous one or
+// the current one.
+func (s *RevisionStore) Get(ctx context.Context, postID int64, version int) (*PostRevision, error) {
+	query := `
+		SELECT post_id, version, title, content, tags, created_at
+		FROM post_revisions
+		WHERE post_id = ? AND version = ?
+		UNION ALL
+		SELECT p.id, IFNULL(p.version, 0), p.title, p.content, ` + postTagsJSON + `, p.updated_at
+		FROM posts p
+		WHERE p.id = ? AND IFNULL(p.version, 0) = ?
+		LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rev := &PostRevision{}
+	row := s.db.QueryRowContext(ctx, query, postID, version, postID, version)
+	if err := scanRevision(row, rev); err != nil {
+		return nil, err
+	}
+
+	return rev, nil
+}
+
+func scanRevision(row rowScanner, rev *PostRevision) error {
+	var tagsSQL sql.NullString
+	if err := row.Scan(&rev.PostID, &rev.Version, &rev.Title, &rev.Content, &tagsSQL, &rev.CreatedAt); err != nil {
+		return err
+	}
+
+	rev.Tags = []string{}
+	if tagsSQL.Valid && tagsSQL.String != "" {
+		return json.Unmarshal([]byte(tagsSQL.String), &rev.Tags)
+	}
+
+	return nil
+}
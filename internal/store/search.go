@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// SearchDocument is what a search index keeps of a published post.
+type SearchDocument struct {
+	PostID      int64
+	AuthorID    int64
+	Title       string
+	Content     string
+	Tags        []string
+	PublishedAt time.Time
+}
+
+// SearchQuery finds posts whose title or content match Text. AuthorID, Tag,
+// Since and Until narrow the results when set; Tag must be normalized.
+type SearchQuery struct {
+	Text     string
+	AuthorID int64
+	Tag      string
+	Since    *time.Time
+	Until    *time.Time
+	Limit    int
+}
+
+// SearchHit is a post matching a search, with its relevance score. Scores
+// are only comparable within the results of one search.
+type SearchHit struct {
+	PostID int64
+	Score  float64
+}
+
+type SearchStore struct {
+	db *sql.DB
+}
+
+// GetDocuments lists the published posts not in the trash with an ID above
+// afterID, by ID, for building a search index in batches.
+func (s *SearchStore) GetDocuments(ctx context.Context, afterID int64, limit int) ([]SearchDocument, error) {
+	query := `
+		SELECT p.id, p.user_id, p.title, p.content, ` + postTagsJSON + `, p.published_at
+		FROM posts p
+		WHERE p.status = 'published' AND p.deleted_at IS NULL AND p.id > ?
+		ORDER BY p.id
+		LIMIT ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []SearchDocument{}
+	for rows.Next() {
+		var doc SearchDocument
+		var tagsSQL sql.NullString
+		if err := rows.Scan(&doc.PostID, &doc.AuthorID, &doc.Title, &doc.Content, &tagsSQL, &doc.PublishedAt); err != nil {
+			return nil, err
+		}
+
+		doc.Tags = []string{}
+		if tagsSQL.Valid && tagsSQL.String != "" {
+			if err := json.Unmarshal([]byte(tagsSQL.String), &doc.Tags); err != nil {
+				return nil, err
+			}
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, rows.Err()
+}
+
+// FullText searches published posts with the FULLTEXT index on title and
+// content, best matches first.
+func (s *SearchStore) FullText(ctx context.Context, q SearchQuery) ([]SearchHit, error) {
+	conditions := []string{}
+	args := []any{q.Text, q.Text}
+
+	if q.AuthorID != 0 {
+		conditions = append(conditions, "p.user_id = ?")
+		args = append(args, q.AuthorID)
+	}
+	if q.Tag != "" {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.post_id = p.id AND t.name = ?)`)
+		args = append(args, q.Tag)
+	}
+	if q.Since != nil {
+		conditions = append(conditions, "p.published_at >= ?")
+		args = append(args, *q.Since)
+	}
+	if q.Until != nil {
+		conditions = append(conditions, "p.published_at < ?")
+		args = append(args, *q.Until)
+	}
+
+	filter := ""
+	if len(conditions) > 0 {
+		filter = " AND " + strings.Join(conditions, " AND ")
+	}
+
+	query := `
+		SELECT p.id, MATCH (p.title, p.content) AGAINST (? IN NATURAL LANGUAGE MODE) AS score
+		FROM posts p
+		WHERE MATCH (p.title, p.content) AGAINST (? IN NATURAL LANGUAGE MODE)
+			AND p.status = 'published' AND p.deleted_at IS NULL` + filter + `
+		ORDER BY score DESC, p.id DESC
+		LIMIT ?`
+	args = append(args, q.Limit)
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []SearchHit{}
+	for rows.Next() {
+		var hit SearchHit
+		if err := rows.Scan(&hit.PostID, &hit.Score); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+
+	return hits, rows.Err()
+}
+
+// GetPosts loads the posts of a search result that the viewer may see, in
+// the given order. Posts the viewer cannot see or has muted are left out.
+func (s *SearchStore) GetPosts(ctx context.Context, viewerID int64, ids []int64) ([]PostWithData, error) {
+	if len(ids) == 0 {
+		return []PostWithData{}, nil
+	}
+
+	query := `
+		SELECT ` + postWithDataColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id IN (` + placeholders(len(ids)) + `) AND p.status = 'published'
+			AND` + muteFilter + `
+			AND` + visibleToViewer
+
+	args := append(int64Args(ids), viewerID, viewerID, viewerID, viewerID, viewerID, viewerID)
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]PostWithData, len(ids))
+	for rows.Next() {
+		var post PostWithData
+		if err := scanPostWithData(rows, &post); err != nil {
+			return nil, err
+		}
+		byID[post.ID] = post
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	posts := make([]PostWithData, 0, len(byID))
+	for _, id := range ids {
+		if post, ok := byID[id]; ok {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
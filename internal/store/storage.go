@@ -4,20 +4,32 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 )
 
 var (
-	Querytimeout = 5 * time.Second
-	ErrConflict  = errors.New("record already exists")
+	Querytimeout    = 5 * time.Second
+	ErrConflict     = errors.New("record already exists")
+	ErrEditConflict = errors.New("edit conflict")
+	// ErrInvalidAttachment is returned when a post references an attachment
+	// that is not an unused upload of its author.
+	ErrInvalidAttachment = errors.New("attachment not found or already used")
 )
 
 type PostStores interface {
 	Create(context.Context, *Post) error
 	GetByID(context.Context, int64) (*Post, error)
+	GetDrafts(context.Context, int64, PaginationQuery) ([]Post, error)
+	Publish(context.Context, *Post) error
+	PublishDue(context.Context, int) ([]Post, error)
+	GetDeletedByID(context.Context, int64) (*Post, error)
+	GetTrash(context.Context, int64, bool, PaginationQuery) ([]Post, error)
+	Restore(context.Context, *Post) error
+	PurgeDeleted(context.Context, time.Duration, int) (int, error)
 	Delete(context.Context, int64) error
 	Update(context.Context, *Post) error
-	GetUserFeed(context.Context, int64, PaginationQuery) ([]PostWithData, error)
+	GetUserFeed(context.Context, int64, PaginationQuery) (*FeedPage, error)
 }
 
 type UserStores interface {
@@ -27,6 +39,8 @@ type UserStores interface {
 	CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration) error
 	Activate(context.Context, string) error
 	Delete(context.Context, int64) error
+	SetPrivate(context.Context, int64, bool) error
+	Search(ctx context.Context, viewerID int64, q string, limit int) ([]UserSearchResult, error)
 }
 
 type Storage struct {
@@ -34,24 +48,142 @@ type Storage struct {
 	Users    UserStores
 	Comments interface {
 		Create(context.Context, *Comment) error
-		GetByPostID(context.Context, int64) ([]Comment, error)
+		GetByID(context.Context, int64) (*Comment, error)
+		GetByPostID(context.Context, int64, CommentPageQuery, CommentTreeQuery) (*CommentPage, error)
+		GetReplies(context.Context, *Comment, CommentTreeQuery) error
+		Update(context.Context, *Comment) error
+		Delete(context.Context, int64) error
 	}
 	Followers interface {
 		Follow(context.Context, int64, int64) error
 		UnFollow(context.Context, int64, int64) error
+		IsFollowing(context.Context, int64, int64) (bool, error)
+		GetFollowerIDs(context.Context, int64) ([]int64, error)
+		RequestFollow(context.Context, int64, int64) error
+		GetFollowRequests(context.Context, int64) ([]FollowRequest, error)
+		ApproveFollowRequest(context.Context, int64, int64) error
+		RejectFollowRequest(context.Context, int64, int64) error
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
 	}
+	Suggestions interface {
+		Get(context.Context, int64, int) ([]Suggestion, error)
+		IsStale(context.Context, int64, time.Duration) (bool, error)
+		RequestRefresh(context.Context, int64) error
+		GetStaleUserIDs(context.Context, time.Duration, int) ([]int64, error)
+		Recompute(context.Context, int64) error
+		Dismiss(context.Context, int64, int64) error
+	}
+	Exports interface {
+		Create(context.Context, *DataExport) error
+		GetByID(context.Context, int64) (*DataExport, error)
+		GetReady(context.Context, int64, time.Duration) (*DataExport, error)
+		ClaimPending(context.Context) (*DataExport, error)
+		FailStale(context.Context, time.Duration) (int64, error)
+		ExpireReady(context.Context, time.Duration, int) ([]DataExport, error)
+		MarkReady(context.Context, int64, string) (time.Time, error)
+		MarkFailed(context.Context, int64, string) error
+		CollectUserData(context.Context, int64) (*UserDataExport, error)
+	}
+	Mentions interface {
+		Sync(ctx context.Context, postID int64, commentID *int64, content string) ([]Mention, []int64, error)
+		GetForPosts(context.Context, []int64) (map[int64][]Mention, error)
+		GetForComments(context.Context, []int64) (map[int64][]Mention, error)
+		GetPostsMentioning(context.Context, int64, PaginationQuery) ([]PostWithData, error)
+	}
+	Notifications interface {
+		Create(context.Context, *Notification) error
+		GetByUserID(ctx context.Context, userID int64, unreadOnly bool, fq PaginationQuery) ([]Notification, error)
+		UnreadCount(context.Context, int64) (int, error)
+		MarkRead(context.Context, int64, int64) error
+		MarkAllRead(context.Context, int64) error
+		QueueEmail(context.Context, int64) error
+		ClaimEmails(context.Context) (int64, []Notification, error)
+	}
+	NotificationPreferences interface {
+		Get(context.Context, int64) (NotificationPreferences, error)
+		GetChannel(context.Context, int64, string) (string, error)
+		Set(context.Context, int64, NotificationPreferences) error
+		UnsubscribeEmail(context.Context, int64) error
+	}
+	Reactions interface {
+		Toggle(ctx context.Context, userID, postID int64, commentID *int64, emoji string) (bool, error)
+		GetForPosts(context.Context, int64, []int64) (map[int64]Reactions, error)
+		GetForComments(context.Context, int64, []int64) (map[int64]Reactions, error)
+		GetReactors(ctx context.Context, postID int64, emoji string, fq PaginationQuery) ([]Reactor, error)
+	}
+	Revisions interface {
+		GetByPostID(context.Context, int64) ([]PostRevision, error)
+		Get(ctx context.Context, postID int64, version int) (*PostRevision, error)
+	}
+	Reposts interface {
+		Create(context.Context, *Repost) error
+		Delete(ctx context.Context, userID, postID int64) error
+		GetCounts(context.Context, []int64) (map[int64]RepostCounts, error)
+	}
+	Bookmarks interface {
+		Save(context.Context, *Bookmark) error
+		Delete(ctx context.Context, userID, postID int64) error
+		GetPosts(ctx context.Context, userID int64, collectionID *int64, cq CursorQuery) (*BookmarkPage, error)
+		CreateCollection(context.Context, *BookmarkCollection) error
+		GetCollections(context.Context, int64) ([]BookmarkCollection, error)
+		DeleteCollection(ctx context.Context, userID, collectionID int64) error
+	}
+	Attachments interface {
+		Create(context.Context, *Attachment) error
+		GetByID(context.Context, int64) (*Attachment, error)
+		UpdateAltText(ctx context.Context, userID, id int64, altText string) error
+		GetForPosts(context.Context, []int64) (map[int64][]Attachment, error)
+		DeleteUnattached(context.Context, time.Duration, int) ([]Attachment, error)
+	}
+	Tags interface {
+		GetTrending(ctx context.Context, window time.Duration, limit int) ([]Tag, error)
+		Autocomplete(ctx context.Context, prefix string, limit int) ([]Tag, error)
+		GetPosts(ctx context.Context, viewerID int64, name string, cq CursorQuery) (*TagPostsPage, error)
+		Follow(ctx context.Context, userID int64, name string) error
+		Unfollow(ctx context.Context, userID int64, name string) error
+		GetFollowed(context.Context, int64) ([]Tag, error)
+	}
+	Search interface {
+		GetDocuments(ctx context.Context, afterID int64, limit int) ([]SearchDocument, error)
+		FullText(context.Context, SearchQuery) ([]SearchHit, error)
+		GetPosts(ctx context.Context, viewerID int64, ids []int64) ([]PostWithData, error)
+	}
+	Mutes interface {
+		Create(context.Context, *Mute) error
+		GetByUserID(context.Context, int64) ([]Mute, error)
+		Delete(context.Context, int64, int64) error
+		FilterMuting(ctx context.Context, postID int64, userIDs []int64) ([]int64, error)
+	}
+	Blocks interface {
+		Block(ctx context.Context, blockerID, blockedID int64) error
+		Unblock(ctx context.Context, blockerID, blockedID int64) error
+		IsBlocked(ctx context.Context, userID, otherID int64) (bool, error)
+	}
 }
 
 func NewSQL(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &Poststore{db},
-		Users:     &Userstore{db},
-		Comments:  &Commentstore{db},
-		Followers: &FollowerStore{db},
-		Roles:     &RoleStore{db},
+		Posts:                   &Poststore{db},
+		Users:                   &Userstore{db},
+		Comments:                &Commentstore{db},
+		Followers:               &FollowerStore{db},
+		Roles:                   &RoleStore{db},
+		Mutes:                   &MuteStore{db},
+		Suggestions:             &SuggestionStore{db},
+		Exports:                 &ExportStore{db},
+		Mentions:                &MentionStore{db},
+		Notifications:           &NotificationStore{db},
+		NotificationPreferences: &NotificationPreferenceStore{db},
+		Reactions:               &ReactionStore{db},
+		Bookmarks:               &BookmarkStore{db},
+		Reposts:                 &RepostStore{db},
+		Revisions:               &RevisionStore{db},
+		Attachments:             &AttachmentStore{db},
+		Tags:                    &TagStore{db},
+		Search:                  &SearchStore{db},
+		Blocks:                  &BlockStore{db},
 	}
 }
 
@@ -68,3 +200,16 @@ func withTx(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
 
 	return tx.Commit()
 }
+
+// placeholders returns "?, ?, ?" with n placeholders for IN clauses.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func int64Args(ids []int64) []any {
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return args
+}
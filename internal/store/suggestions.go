@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type Suggestion struct {
+	User        User `json:"user"`
+	Score       int  `json:"score"`
+	MutualCount int  `json:"mutual_count"`
+	SharedTags  int  `json:"shared_tags"`
+}
+
+type SuggestionStore struct {
+	db *sql.DB
+}
+
+// maxSuggestions is how many suggestions are kept per user on each run.
+const maxSuggestions = 50
+
+// notBlocked leaves out candidates aliased as u that block or are blocked by
+// the user. It takes the user ID twice.
+const notBlocked = `NOT EXISTS (
+		SELECT 1 FROM user_blocks ub
+		WHERE (ub.blocker_id = ? AND ub.blocked_id = u.id) OR (ub.blocker_id = u.id AND ub.blocked_id = ?)
+	)`
+
+// Get returns the cached suggestions of a user, skipping accounts the user
+// followed, requested to follow or blocked since the last computation, and
+// accounts that blocked the user since.
+func (s *SuggestionStore) Get(ctx context.Context, userID int64, limit int) ([]Suggestion, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.is_private, fs.score, fs.mutual_count, fs.shared_tags
+		FROM follow_suggestions fs
+		JOIN users u ON u.id = fs.suggested_id
+		WHERE fs.user_id = ?
+			AND u.is_active = TRUE
+			AND u.suspended_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM followers f WHERE f.user_id = fs.suggested_id AND f.follower_id = fs.user_id)
+			AND NOT EXISTS (SELECT 1 FROM follow_requests fr WHERE fr.user_id = fs.suggested_id AND fr.follower_id = fs.user_id)
+			AND ` + notBlocked + `
+		ORDER BY fs.score DESC, u.id ASC
+		LIMIT ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, userID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []Suggestion{}
+	for rows.Next() {
+		var sg Suggestion
+		err := rows.Scan(
+			&sg.User.ID,
+			&sg.User.Username,
+			&sg.User.DisplayName,
+			&sg.User.IsPrivate,
+			&sg.Score,
+			&sg.MutualCount,
+			&sg.SharedTags,
+		)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, sg)
+	}
+
+	return suggestions, rows.Err()
+}
+
+// IsStale reports whether the suggestions of a user were never computed or
+// were computed more than maxAge ago.
+func (s *SuggestionStore) IsStale(ctx context.Context, userID int64, maxAge time.Duration) (bool, error) {
+	query := `SELECT computed_at < NOW() - INTERVAL ? SECOND FROM follow_suggestion_runs WHERE user_id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	var stale bool
+	err := s.db.QueryRowContext(ctx, query, int64(maxAge.Seconds()), userID).Scan(&stale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return stale, nil
+}
+
+// RequestRefresh queues the first computation of the suggestions of a user.
+// The run is dated at the epoch so that GetStaleUserIDs returns it first.
+// Users whose suggestions were computed before are left as they are.
+func (s *SuggestionStore) RequestRefresh(ctx context.Context, userID int64) error {
+	query := `INSERT IGNORE INTO follow_suggestion_runs (user_id, computed_at) VALUES (?, FROM_UNIXTIME(1))`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// GetStaleUserIDs returns users whose cached suggestions are older than
+// maxAge, oldest first. Only users that asked for suggestions at least once
+// have a cache.
+func (s *SuggestionStore) GetStaleUserIDs(ctx context.Context, maxAge time.Duration, limit int) ([]int64, error) {
+	query := `
+		SELECT user_id FROM follow_suggestion_runs
+		WHERE computed_at < NOW() - INTERVAL ? SECOND
+		ORDER BY computed_at ASC
+		LIMIT ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, int64(maxAge.Seconds()), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Recompute rebuilds the suggestions of a user from friends-of-friends (people
+// followed by the people the user follows) and shared-tag affinity (authors
+// posting with tags the user posts with). A mutual connection weighs three
+// times as much as a shared tag.
+func (s *SuggestionStore) Recompute(ctx context.Context, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM follow_suggestions WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+
+		query := `
+		INSERT INTO follow_suggestions (user_id, suggested_id, score, mutual_count, shared_tags)
+		SELECT ?, c.id, SUM(c.mutual) * 3 + SUM(c.shared), SUM(c.mutual), SUM(c.shared)
+		FROM (
+			SELECT f2.user_id AS id, COUNT(DISTINCT f1.user_id) AS mutual, 0 AS shared
+			FROM followers f1
+			JOIN followers f2 ON f2.follower_id = f1.user_id
+			WHERE f1.follower_id = ?
+			GROUP BY f2.user_id
+
+			UNION ALL
+
+			SELECT p2.user_id AS id, 0 AS mutual, COUNT(DISTINCT t2.tag_id) AS shared
+			FROM posts p2
+			JOIN post_tags t2 ON t2.post_id = p2.id
+			WHERE p2.status = 'published' AND p2.visibility = 'public' AND p2.deleted_at IS NULL AND t2.tag_id IN (
+				SELECT t1.tag_id
+				FROM posts p1
+				JOIN post_tags t1 ON t1.post_id = p1.id
+				WHERE p1.user_id = ?
+			)
+			GROUP BY p2.user_id
+		) c
+		JOIN users u ON u.id = c.id
+		WHERE c.id <> ?
+			AND u.is_active = TRUE
+			AND u.suspended_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM followers f WHERE f.user_id = c.id AND f.follower_id = ?)
+			AND NOT EXISTS (SELECT 1 FROM follow_requests fr WHERE fr.user_id = c.id AND fr.follower_id = ?)
+			AND NOT EXISTS (SELECT 1 FROM follow_suggestion_dismissals d WHERE d.user_id = ? AND d.dismissed_id = c.id)
+			AND ` + notBlocked + `
+		GROUP BY c.id
+		ORDER BY 3 DESC
+		LIMIT ?`
+
+		_, err := tx.ExecContext(ctx, query, userID, userID, userID, userID, userID, userID, userID, userID, userID, maxSuggestions)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO follow_suggestion_runs (user_id, computed_at) VALUES (?, NOW())
+			ON DUPLICATE KEY UPDATE computed_at = NOW()`, userID)
+		return err
+	})
+}
+
+// Dismiss hides a suggested user for good.
+func (s *SuggestionStore) Dismiss(ctx context.Context, userID, dismissedID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT IGNORE INTO follow_suggestion_dismissals (user_id, dismissed_id, created_at)
+			VALUES (?, ?, NOW())`, userID, dismissedID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM follow_suggestions WHERE user_id = ? AND suggested_id = ?`, userID, dismissedID)
+		return err
+	})
+}
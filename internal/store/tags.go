@@ -0,0 +1,288 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxTagLength and MaxPostTags keep the tags of a post, aggregated by
+	// postTagsJSON, under MySQL's default group_concat_max_len.
+	MaxTagLength = 30
+	MaxPostTags  = 8
+)
+
+var (
+	ErrInvalidTag = errors.New("tags may only contain letters, digits and underscores")
+	tagPattern    = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+)
+
+// NormalizeTag turns a tag as typed by a user into its stored form: spaces
+// and a leading '#' are dropped and letters are lowercased. What remains must
+// be 1 to MaxTagLength letters, digits or underscores.
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" || len([]rune(tag)) > MaxTagLength || !tagPattern.MatchString(tag) {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+// NormalizeTags normalizes the tags of a post, keeping the first occurrence
+// of tags that normalize to the same name.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		name, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized, nil
+}
+
+// postTagsJSON selects the tags of the post aliased as p as a JSON array in
+// their order, or NULL when it has none.
+const postTagsJSON = `(SELECT CONCAT('[', GROUP_CONCAT(JSON_QUOTE(tg.name) ORDER BY ptg.position SEPARATOR ','), ']')
+		FROM post_tags ptg JOIN tags tg ON tg.id = ptg.tag_id WHERE ptg.post_id = p.id)`
+
+// Tag is a tag with how much it is used: in how many posts and, for
+// trending tags, by how many authors.
+type Tag struct {
+	Name    string `json:"name"`
+	Posts   int    `json:"posts"`
+	Authors int    `json:"authors,omitempty"`
+}
+
+type TagStore struct {
+	db *sql.DB
+}
+
+// publicTaggedPost restricts post_tags aliased as pt to posts anyone may
+// read: published, public, not in the trash and by a public account.
+const publicTaggedPost = `
+	JOIN posts p ON p.id = pt.post_id
+	JOIN users u ON u.id = p.user_id
+	WHERE p.status = 'published' AND p.visibility = 'public' AND p.deleted_at IS NULL AND u.is_private = FALSE`
+
+// GetTrending lists the tags used the most in public posts published within
+// the last window. Tags used by more authors rank first, so one account
+// posting a lot cannot push a tag up on its own.
+func (s *TagStore) GetTrending(ctx context.Context, window time.Duration, limit int) ([]Tag, error) {
+	query := `
+		SELECT t.name, COUNT(*) AS posts, COUNT(DISTINCT p.user_id) AS authors
+		FROM post_tags pt
+		JOIN tags t ON t.id = pt.tag_id` + publicTaggedPost + `
+			AND p.published_at >= NOW() - INTERVAL ? SECOND
+		GROUP BY t.id, t.name
+		ORDER BY authors DESC, posts DESC, t.name
+		LIMIT ?`
+
+	return s.list(ctx, query, int64(window.Seconds()), limit)
+}
+
+// Autocomplete lists the tags starting with prefix that are used in public
+// posts, most used first. The prefix is expected to be normalized.
+func (s *TagStore) Autocomplete(ctx context.Context, prefix string, limit int) ([]Tag, error) {
+	// '_' là ký tự hợp lệ trong tag nhưng là wildcard của LIKE
+	pattern := strings.ReplaceAll(prefix, "_", `\_`) + "%"
+
+	query := `
+		SELECT t.name, COUNT(*) AS posts, 0
+		FROM tags t
+		JOIN post_tags pt ON pt.tag_id = t.id` + publicTaggedPost + `
+			AND t.name LIKE ?
+		GROUP BY t.id, t.name
+		ORDER BY posts DESC, t.name
+		LIMIT ?`
+
+	return s.list(ctx, query, pattern, limit)
+}
+
+func (s *TagStore) list(ctx context.Context, query string, args ...any) ([]Tag, error) {
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.Name, &t.Posts, &t.Authors); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+// setPostTags replaces the tags of a post with post.Tags, creating the tags
+// that do not exist yet. The tags must already be normalized.
+func setPostTags(ctx context.Context, tx *sql.Tx, post *Post) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_tags WHERE post_id = ?`, post.ID); err != nil {
+		return err
+	}
+
+	for i, name := range post.Tags {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO tags (name) VALUES (?)
+			ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`, name)
+		if err != nil {
+			return err
+		}
+
+		tagID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO post_tags (post_id, tag_id, position) VALUES (?, ?, ?)`, post.ID, tagID, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TagPostsPage is a page of the posts with a tag, newest first.
+type TagPostsPage struct {
+	Posts      []PostWithData `json:"posts"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+// GetPosts lists the published posts with a tag that the viewer may see,
+// newest first, leaving out posts matched by the viewer's mutes.
+func (s *TagStore) GetPosts(ctx context.Context, viewerID int64, name string, cq CursorQuery) (*TagPostsPage, error) {
+	args := []any{name, viewerID, viewerID, viewerID, viewerID, viewerID, viewerID}
+
+	keyset := "TRUE"
+	if cq.Cursor != "" {
+		values, err := decodeCursor(cq.Cursor, 2)
+		if err != nil {
+			return nil, err
+		}
+		keyset = "(p.published_at < FROM_UNIXTIME(?) OR (p.published_at = FROM_UNIXTIME(?) AND p.id < ?))"
+		args = append(args, values[0], values[0], values[1])
+	}
+	args = append(args, cq.Limit+1)
+
+	query := `
+		SELECT ` + postWithDataColumns + `, UNIX_TIMESTAMP(p.published_at)
+		FROM tags t
+		JOIN post_tags pt ON pt.tag_id = t.id
+		JOIN posts p ON p.id = pt.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE t.name = ? AND p.status = 'published'
+			AND` + muteFilter + `
+			AND` + visibleToViewer + `
+			AND ` + keyset + `
+		ORDER BY p.published_at DESC, p.id DESC
+		LIMIT ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &TagPostsPage{Posts: []PostWithData{}}
+	var lastID, lastPublished int64
+	for rows.Next() {
+		var post PostWithData
+		var publishedAt int64
+		if err := scanPostWithData(rows, &post, &publishedAt); err != nil {
+			return nil, err
+		}
+
+		if len(page.Posts) == cq.Limit {
+			cursor := encodeCursor(lastPublished, lastID)
+			page.NextCursor = &cursor
+			break
+		}
+
+		page.Posts = append(page.Posts, post)
+		lastID, lastPublished = post.ID, publishedAt
+	}
+
+	return page, rows.Err()
+}
+
+// Follow makes the user follow a tag, creating the tag when nobody used it
+// yet. Following a tag again is a no-op.
+func (s *TagStore) Follow(ctx context.Context, userID int64, name string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO tags (name) VALUES (?)
+			ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`, name)
+		if err != nil {
+			return err
+		}
+
+		tagID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT IGNORE INTO tag_follows (user_id, tag_id, created_at) VALUES (?, ?, NOW())`, userID, tagID)
+		return err
+	})
+}
+
+// Unfollow returns sql.ErrNoRows when the user does not follow the tag.
+func (s *TagStore) Unfollow(ctx context.Context, userID int64, name string) error {
+	query := `
+		DELETE tf FROM tag_follows tf
+		JOIN tags t ON t.id = tf.tag_id
+		WHERE tf.user_id = ? AND t.name = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, name)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetFollowed lists the tags the user follows by name, with how many public
+// posts use them.
+func (s *TagStore) GetFollowed(ctx context.Context, userID int64) ([]Tag, error) {
+	query := `
+		SELECT t.name, (SELECT COUNT(*) FROM post_tags pt` + publicTaggedPost + ` AND pt.tag_id = t.id), 0
+		FROM tag_follows tf
+		JOIN tags t ON t.id = tf.tag_id
+		WHERE tf.user_id = ?
+		ORDER BY t.name`
+
+	return s.list(ctx, query, userID)
+}
@@ -7,6 +7,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"log"
+	"sort"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -18,14 +20,16 @@ var (
 )
 
 type User struct {
-	ID        int64     `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  password  `json:"-"`
-	CreatedAt time.Time `json:"createdat"`
-	IsActive  bool      `json:"is_active"`
-	RoleID    int64    `json:"role_id"`
-	Role      Role     `json:"role"`
+	ID          int64     `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Email       string    `json:"email"`
+	Password    password  `json:"-"`
+	CreatedAt   time.Time `json:"createdat"`
+	IsActive    bool      `json:"is_active"`
+	IsPrivate   bool      `json:"is_private"`
+	RoleID      int64     `json:"role_id"`
+	Role        Role      `json:"role"`
 }
 
 type password struct {
@@ -51,8 +55,8 @@ type Userstore struct {
 
 func (s *Userstore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 	query := `
-    INSERT INTO users (username, password, email, role_id)
-    VALUES (?, ?, ?, (SELECT id FROM roles WHERE name = ?))
+    INSERT INTO users (username, display_name, password, email, role_id)
+    VALUES (?, ?, ?, ?, (SELECT id FROM roles WHERE name = ?))
 		`
 
 	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
@@ -66,6 +70,7 @@ func (s *Userstore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 		ctx,
 		query,
 		user.Username,
+		user.DisplayName,
 		user.Password.hash,
 		user.Email,
 		role,
@@ -109,9 +114,11 @@ func (s *Userstore) GetByID(ctx context.Context, id int64) (*User, error) {
 		SELECT 
 	users.id,
 	users.username,
+	users.display_name,
 	users.email,
 	users.password,
 	users.created_at,
+	users.is_private,
 	roles.id,
 	roles.name,
 	roles.description
@@ -128,9 +135,11 @@ func (s *Userstore) GetByID(ctx context.Context, id int64) (*User, error) {
 	err := s.db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Username,
+		&user.DisplayName,
 		&user.Email,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.IsPrivate,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Description,
@@ -305,3 +314,152 @@ func (s *Userstore) GetByEmail(ctx context.Context, email string) (*User, error)
 
 	return user, nil
 }
+
+// SetPrivate switches a user between a public and a private account. Making
+// an account public approves all of its pending follow requests.
+func (s *Userstore) SetPrivate(ctx context.Context, userID int64, private bool) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET is_private = ? WHERE id = ?`, private, userID); err != nil {
+			return err
+		}
+
+		if private {
+			return nil
+		}
+
+		query := `
+			INSERT IGNORE INTO followers (user_id, follower_id, created_at)
+			SELECT user_id, follower_id, NOW() FROM follow_requests WHERE user_id = ?`
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM follow_requests WHERE user_id = ?`, userID)
+		return err
+	})
+}
+
+type UserSearchResult struct {
+	ID            int64  `json:"id"`
+	Username      string `json:"username"`
+	DisplayName   string `json:"display_name"`
+	IsPrivate     bool   `json:"is_private"`
+	FollowerCount int    `json:"follower_count"`
+	IsMutual      bool   `json:"is_mutual"`
+	exactMatch    bool
+}
+
+// Search finds active users whose username or display name matches q,
+// leaving out suspended users and users blocking or blocked by the viewer.
+// The prefix pass finds its candidates through the username and display name
+// indexes and only counts the followers of the best searchCandidates; the
+// fuzzy (contains / sounds-like) pass scans the users table, so it only runs
+// when prefixes alone cannot fill the page. Results are ranked by exact
+// match, then mutual follows, then follower count.
+func (s *Userstore) Search(ctx context.Context, viewerID int64, q string, limit int) ([]UserSearchResult, error) {
+	prefix := escapeLike(q) + "%"
+
+	results, err := s.search(ctx, viewerID, q, `(u.username LIKE ? OR u.display_name LIKE ?)`, []any{prefix, prefix}, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) < limit && len([]rune(q)) >= 3 {
+		contains := "%" + escapeLike(q) + "%"
+		cond := `(u.display_name LIKE ? OR u.username LIKE ? OR SOUNDEX(u.username) = SOUNDEX(?))
+			AND u.username NOT LIKE ? AND u.display_name NOT LIKE ?`
+
+		fuzzy, err := s.search(ctx, viewerID, q, cond, []any{contains, contains, q, prefix, prefix}, limit-len(results))
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, fuzzy...)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.exactMatch != b.exactMatch {
+			return a.exactMatch
+		}
+		if a.IsMutual != b.IsMutual {
+			return a.IsMutual
+		}
+		return a.FollowerCount > b.FollowerCount
+	})
+
+	return results, nil
+}
+
+// searchCandidates bounds how many matching users a search pass ranks by
+// follower count, so a short prefix does not count the followers of every
+// user it matches.
+const searchCandidates = 200
+
+func (s *Userstore) search(ctx context.Context, viewerID int64, q, cond string, condArgs []any, limit int) ([]UserSearchResult, error) {
+	// ứng viên được chọn theo exact match và mutual (tra theo khoá chính),
+	// chỉ đếm follower cho tập ứng viên đó
+	query := `
+		WITH candidates AS (
+			SELECT
+				u.id,
+				u.username,
+				u.display_name,
+				u.is_private,
+				(EXISTS (SELECT 1 FROM followers a WHERE a.user_id = u.id AND a.follower_id = ?)
+					AND EXISTS (SELECT 1 FROM followers b WHERE b.user_id = ? AND b.follower_id = u.id)) AS is_mutual,
+				(u.username = ? OR u.display_name = ?) AS exact_match
+			FROM users u
+			WHERE u.is_active = TRUE
+				AND u.suspended_at IS NULL
+				AND u.id <> ?
+				AND NOT EXISTS (
+					SELECT 1 FROM user_blocks ub
+					WHERE (ub.blocker_id = ? AND ub.blocked_id = u.id) OR (ub.blocker_id = u.id AND ub.blocked_id = ?)
+				)
+				AND ` + cond + `
+			ORDER BY exact_match DESC, is_mutual DESC, u.username ASC
+			LIMIT ?
+		), follower_counts AS (
+			SELECT f.user_id, COUNT(*) AS n
+			FROM followers f
+			JOIN candidates c ON c.id = f.user_id
+			GROUP BY f.user_id
+		)
+		SELECT c.id, c.username, c.display_name, c.is_private, COALESCE(fc.n, 0) AS follower_count, c.is_mutual, c.exact_match
+		FROM candidates c
+		LEFT JOIN follower_counts fc ON fc.user_id = c.id
+		ORDER BY c.exact_match DESC, c.is_mutual DESC, follower_count DESC, c.username ASC
+		LIMIT ?`
+
+	args := []any{viewerID, viewerID, q, q, viewerID, viewerID, viewerID}
+	args = append(args, condArgs...)
+	args = append(args, max(limit, searchCandidates), limit)
+
+	ctx, cancel := context.WithTimeout(ctx, Querytimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []UserSearchResult{}
+	for rows.Next() {
+		var u UserSearchResult
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.IsPrivate, &u.FollowerCount, &u.IsMutual, &u.exactMatch); err != nil {
+			return nil, err
+		}
+		results = append(results, u)
+	}
+
+	return results, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards in user input.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}